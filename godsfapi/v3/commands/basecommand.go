@@ -6,6 +6,12 @@ type Command interface {
 	GetCommand() string
 }
 
+// Validator is implemented by commands that can check their settings before they are sent
+type Validator interface {
+	// Validate returns an error if the command would be rejected by the control server
+	Validate() error
+}
+
 // BaseCommand is the common base member of nearly all actual commands
 type BaseCommand struct {
 	Command string
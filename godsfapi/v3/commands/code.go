@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -23,6 +25,79 @@ func (cr CodeResult) String() string {
 	return b.String()
 }
 
+// UnmarshalJSON decodes a list of messages as well as a single message. Newer control servers
+// reply to codes with one message while older ones send a list of messages.
+func (cr *CodeResult) UnmarshalJSON(data []byte) error {
+	if d := bytes.TrimSpace(data); len(d) > 0 && d[0] == '{' {
+		var m messages.Message
+		if err := json.Unmarshal(d, &m); err != nil {
+			return err
+		}
+		*cr = CodeResult{m}
+		return nil
+	}
+	var ms []messages.Message
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return err
+	}
+	*cr = CodeResult(ms)
+	return nil
+}
+
+// FullText joins the text of all messages in the order they were received using new lines.
+// Messages keep their "Error: " and "Warning: " prefixes and there is no trailing new line.
+func (cr CodeResult) FullText() string {
+	lines := make([]string, 0, len(cr))
+	for _, m := range cr {
+		if m.Content != "" {
+			lines = append(lines, strings.TrimRight(m.String(), "\r\n"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Fragments returns a copy of the messages as they were received, i.e. one message per reply
+// fragment with its original type and possibly spanning multiple lines
+func (cr CodeResult) Fragments() []messages.Message {
+	return append([]messages.Message(nil), cr...)
+}
+
+// Messages returns all messages of this result in the order they were received with
+// one message per line (see messages.Message.Split)
+func (cr CodeResult) Messages() []messages.Message {
+	var result []messages.Message
+	for _, m := range cr {
+		result = append(result, m.Split()...)
+	}
+	return result
+}
+
+// HasError checks if any line of this result is an error message
+func (cr CodeResult) HasError() bool {
+	return len(cr.Errors()) > 0
+}
+
+// Errors returns all error messages of this result
+func (cr CodeResult) Errors() []messages.Message {
+	return cr.filter(messages.Error)
+}
+
+// Warnings returns all warning messages of this result
+func (cr CodeResult) Warnings() []messages.Message {
+	return cr.filter(messages.Warning)
+}
+
+// filter returns all messages of the given type
+func (cr CodeResult) filter(t messages.MessageType) []messages.Message {
+	var result []messages.Message
+	for _, m := range cr.Messages() {
+		if m.Type == t {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
 // CodeFlags are bit masks to classify G/M/T-codes
 type CodeFlags int64
 
@@ -63,11 +138,13 @@ const (
 	// Comment if this code is whole line comment
 	Comment CodeType = "Q"
 	// GCode if this code is a G-Code
-	GCode = "G"
+	GCode CodeType = "G"
 	// MCode if this code is a M-Code
-	MCode = "M"
+	MCode CodeType = "M"
 	// TCode if this code is a T-Code
-	TCode = "T"
+	TCode CodeType = "T"
+	// KeywordCode if this code is a meta G-code keyword such as if, echo or abort (see Code.Keyword)
+	KeywordCode CodeType = "K"
 )
 
 // KeywordType is the type of conditional G-code
@@ -98,6 +175,8 @@ const (
 	Echo
 	// Continue instruction (used in While)
 	Continue
+	// Global operation
+	Global
 )
 
 func (k KeywordType) String() string {
@@ -106,8 +185,12 @@ func (k KeywordType) String() string {
 		return "abort"
 	case Break:
 		return "break"
+	case Continue:
+		return "continue"
 	case Echo:
 		return "echo"
+	case Global:
+		return "global"
 	case Else:
 		return "else"
 	case ElseIf:
@@ -127,6 +210,27 @@ func (k KeywordType) String() string {
 	}
 }
 
+// keywords maps the text representation of all keywords to their types
+var keywords = map[string]KeywordType{
+	"if": If, "elif": ElseIf, "else": Else, "while": While, "break": Break, "return": Return,
+	"abort": Abort, "var": Var, "set": Set, "echo": Echo, "continue": Continue, "global": Global,
+}
+
+// validateArgument checks if the given argument is allowed for this keyword
+func (k KeywordType) validateArgument(argument string) error {
+	switch k {
+	case If, ElseIf, While, Var, Set, Global:
+		if argument == "" {
+			return fmt.Errorf("Missing argument for %s", k)
+		}
+	case Else, Break, Continue:
+		if argument != "" {
+			return fmt.Errorf("Unexpected argument for %s", k)
+		}
+	}
+	return nil
+}
+
 // Code is a parsed representation of a generic G/M/T/code
 type Code struct {
 	BaseCommand
@@ -175,6 +279,24 @@ func NewCode() *Code {
 	}
 }
 
+// Validate checks if the channel, the keyword argument and the parameters of this code are valid
+func (c *Code) Validate() error {
+	if err := c.Channel.Validate(); err != nil {
+		return err
+	}
+	if c.Keyword != None {
+		if err := c.Keyword.validateArgument(c.KeywordArgument); err != nil {
+			return err
+		}
+	}
+	for _, p := range c.Parameters {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Clone an existing Code into a new instance
 func (c *Code) Clone() *Code {
 	cc := *c
@@ -194,6 +316,38 @@ func (c *Code) IsMajorNumber(n int64) bool {
 	return c.MajorNumber != nil && *c.MajorNumber == n
 }
 
+// MatchesFilter checks if this code matches an interception filter like the control server does,
+// e.g. G1, M584.1, T* (asterisks match any number), Q for comments or keywords such as if or elif
+func (c *Code) MatchesFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	if c.Keyword != None {
+		return strings.EqualFold(filter, c.Keyword.String())
+	}
+	if !strings.EqualFold(filter[:1], string(c.Type)) {
+		return false
+	}
+	number := filter[1:]
+	if number == "" || number == "*" {
+		return true
+	}
+	if c.MajorNumber == nil {
+		return false
+	}
+	major, minor := number, ""
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		major, minor = number[:i], number[i+1:]
+	}
+	if major != "*" && major != fmt.Sprintf("%d", *c.MajorNumber) {
+		return false
+	}
+	if minor == "" || minor == "*" {
+		return true
+	}
+	return c.MinorNumber != nil && minor == fmt.Sprintf("%d", *c.MinorNumber)
+}
+
 // HasFlag checks if this code has the given flag set
 func (c *Code) HasFlag(flag CodeFlags) bool {
 	return (c.Flags & flag) != 0
@@ -217,6 +371,13 @@ func (c *Code) Parameter(letter string) *CodeParameter {
 	return nil
 }
 
+// LookupParameter retrieves a parameter for the given letter and reports whether it was found.
+// Lookup is case-insensitive.
+func (c *Code) LookupParameter(letter string) (*CodeParameter, bool) {
+	p := c.Parameter(letter)
+	return p, p != nil
+}
+
 // ParameterOrDefault will return the Parameter for the given letter or return the given default value.
 // Lookup is case-insensitive.
 func (c *Code) ParameterOrDefault(letter string, value interface{}) *CodeParameter {
@@ -260,13 +421,15 @@ func (c *Code) GetUnprecedentedString(quote bool) string {
 		if b.Len() > 0 {
 			b.WriteString(" ")
 		}
-		b.WriteString(p.Letter)
-		if quote && p.IsString {
-			b.WriteString(`"`)
+		if p.Letter != LetterForUnprecentedString {
+			b.WriteString(p.Letter)
 		}
-		b.WriteString(p.AsString())
-		if quote && p.IsString {
+		if quote && p.IsString && !p.IsExpression {
+			b.WriteString(`"`)
+			b.WriteString(escapeString(p.AsString()))
 			b.WriteString(`"`)
+		} else {
+			b.WriteString(p.AsString())
 		}
 	}
 	return b.String()
@@ -274,21 +437,22 @@ func (c *Code) GetUnprecedentedString(quote bool) string {
 
 // String will convert the parsed code back to a text-based G/M/T-code
 func (c *Code) String() string {
-	if c.Keyword != None {
-		if c.KeywordArgument != "" {
-			return fmt.Sprintf("%s %s", c.Keyword.String(), c.KeywordArgument)
-		}
-		return c.Keyword.String()
-	}
-	if c.Type == Comment {
+	if c.Type == Comment && c.Keyword == None {
 		return ";" + c.Comment
 	}
 	var b strings.Builder
 	b.WriteString(c.ShortString())
 
-	for _, p := range c.Parameters {
-		b.WriteString(" ")
-		b.WriteString(p.String())
+	if c.Keyword != None {
+		if c.KeywordArgument != "" {
+			b.WriteString(" ")
+			b.WriteString(c.KeywordArgument)
+		}
+	} else {
+		for _, p := range c.Parameters {
+			b.WriteString(" ")
+			b.WriteString(p.String())
+		}
 	}
 
 	if c.Comment != "" {
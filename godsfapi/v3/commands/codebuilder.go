@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// CodeBuilder creates Code instances using a chainable API, e.g.
+//
+//	code, err := NewCodeBuilder().Channel(types.SBC).G(1).Param("X", 10.0).Param("F", 3000).Build()
+//
+// The first error encountered is kept and returned by Build.
+type CodeBuilder struct {
+	code *Code
+	err  error
+}
+
+// NewCodeBuilder creates a new CodeBuilder for a Code with default values
+func NewCodeBuilder() *CodeBuilder {
+	return &CodeBuilder{code: NewCode()}
+}
+
+// Channel sets the channel the code will be sent to
+func (cb *CodeBuilder) Channel(channel types.CodeChannel) *CodeBuilder {
+	cb.code.Channel = channel
+	return cb
+}
+
+// G makes this a G-code with the given major number
+func (cb *CodeBuilder) G(major int64) *CodeBuilder {
+	return cb.setType(GCode, major)
+}
+
+// M makes this a M-code with the given major number
+func (cb *CodeBuilder) M(major int64) *CodeBuilder {
+	return cb.setType(MCode, major)
+}
+
+// T makes this a T-code selecting the given tool. Use -1 to deselect all tools.
+func (cb *CodeBuilder) T(tool int64) *CodeBuilder {
+	return cb.setType(TCode, tool)
+}
+
+// Keyword makes this a meta G-code keyword with the given argument, e.g. Keyword(If, "move.axes[0].homed").
+// The argument is sent as is so strings inside of it have to be quoted.
+func (cb *CodeBuilder) Keyword(keyword KeywordType, argument string) *CodeBuilder {
+	if cb.err != nil {
+		return cb
+	}
+	if cb.code.Type != Comment {
+		cb.err = fmt.Errorf("Code type already set to %s", cb.code.Type)
+		return cb
+	}
+	if _, ok := keywords[keyword.String()]; !ok {
+		cb.err = fmt.Errorf("Invalid keyword %d", keyword)
+		return cb
+	}
+	cb.code.Type = KeywordCode
+	cb.code.Keyword = keyword
+	cb.code.KeywordArgument = argument
+	return cb
+}
+
+// Echo makes this an echo keyword printing the given message. The result of the code is the message.
+func (cb *CodeBuilder) Echo(message string) *CodeBuilder {
+	return cb.Keyword(Echo, `"`+escapeString(message)+`"`)
+}
+
+// Abort makes this an abort keyword cancelling the current job or macro with the given
+// message. The message is omitted if it is empty.
+func (cb *CodeBuilder) Abort(message string) *CodeBuilder {
+	if message == "" {
+		return cb.Keyword(Abort, "")
+	}
+	return cb.Keyword(Abort, `"`+escapeString(message)+`"`)
+}
+
+// Minor sets the minor number of the code (e.g. 3 in G54.3)
+func (cb *CodeBuilder) Minor(minor int8) *CodeBuilder {
+	if cb.err != nil {
+		return cb
+	}
+	if minor < 0 {
+		cb.err = fmt.Errorf("Invalid minor number %d", minor)
+		return cb
+	}
+	cb.code.MinorNumber = &minor
+	return cb
+}
+
+// Param adds a parameter with the given letter and value. The letter is converted to upper-case.
+// Supported values are strings, numbers, types.DriverId and slices of those except for strings.
+func (cb *CodeBuilder) Param(letter string, value interface{}) *CodeBuilder {
+	if cb.err != nil {
+		return cb
+	}
+	l := strings.ToUpper(letter)
+	if len(l) != 1 || ((l[0] < 'A' || l[0] > 'Z') && l != LetterForUnprecentedString) {
+		cb.err = fmt.Errorf("Invalid parameter letter %s", letter)
+		return cb
+	}
+	if cb.code.HasParameter(l) {
+		cb.err = fmt.Errorf("Duplicate %s parameter", l)
+		return cb
+	}
+	p, err := newBuilderParameter(l, value)
+	if err != nil {
+		cb.err = err
+		return cb
+	}
+	cb.code.Parameters = append(cb.code.Parameters, *p)
+	return cb
+}
+
+// Comment sets the comment of the code
+func (cb *CodeBuilder) Comment(comment string) *CodeBuilder {
+	cb.code.Comment = comment
+	return cb
+}
+
+// Flags adds the given flags to the code
+func (cb *CodeBuilder) Flags(flags CodeFlags) *CodeBuilder {
+	cb.code.Flags |= flags
+	return cb
+}
+
+// Build validates and returns the code. The builder must not be re-used afterwards.
+func (cb *CodeBuilder) Build() (*Code, error) {
+	if cb.err != nil {
+		return nil, cb.err
+	}
+	c := cb.code
+	if c.Type == Comment {
+		if c.MinorNumber != nil || len(c.Parameters) > 0 {
+			return nil, errors.New("Missing code type for minor number or parameters")
+		}
+		if c.Comment == "" {
+			return nil, errors.New("Neither code type nor comment given")
+		}
+	}
+	if c.Type == KeywordCode && (c.MinorNumber != nil || len(c.Parameters) > 0) {
+		return nil, errors.New("Keywords do not support minor numbers or parameters")
+	}
+	if c.Type == TCode && c.MinorNumber != nil {
+		return nil, errors.New("T-codes do not support minor numbers")
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// setType sets type and major number of the code
+func (cb *CodeBuilder) setType(t CodeType, major int64) *CodeBuilder {
+	if cb.err != nil {
+		return cb
+	}
+	if cb.code.Type != Comment {
+		cb.err = fmt.Errorf("Code type already set to %s", cb.code.Type)
+		return cb
+	}
+	if major < 0 && !(t == TCode && major == -1) {
+		cb.err = fmt.Errorf("Invalid major number %s%d", t, major)
+		return cb
+	}
+	cb.code.Type = t
+	cb.code.MajorNumber = &major
+	return cb
+}
+
+// newBuilderParameter creates a CodeParameter from an arbitrary value the same way
+// as if it had been parsed from its text representation
+func newBuilderParameter(letter string, value interface{}) (*CodeParameter, error) {
+	isDriverId := false
+	switch v := value.(type) {
+	case string:
+		return NewSimpleCodeParameter(letter, v), nil
+	case types.DriverId, []types.DriverId:
+		isDriverId = true
+	case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		[]float64, []int64, []uint64, []int:
+	default:
+		return nil, fmt.Errorf("Unsupported value %v of type %T for %s parameter", value, value, letter)
+	}
+	return NewCodeParameter(letter, formatValue(value), false, isDriverId)
+}
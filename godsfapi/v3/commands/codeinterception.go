@@ -32,3 +32,8 @@ func NewResolve(mType messages.MessageType, content string) *Resolve {
 		Content:     content,
 	}
 }
+
+// NewResolveMessage creates a new Resolve from an existing messages.Message instance
+func NewResolveMessage(message messages.Message) *Resolve {
+	return NewResolve(message.Type, message.Content)
+}
@@ -14,6 +14,12 @@ import (
 // ErrMissingParameter if a parameter was not available
 var ErrMissingParameter = errors.New("Parameter not found")
 
+// ErrInvalidParameter if a parameter is malformed and would not be understood by the control server
+var ErrInvalidParameter = errors.New("Invalid code parameter")
+
+// ErrUnevaluatedExpression if the value of a parameter is an expression that has not been evaluated yet
+var ErrUnevaluatedExpression = errors.New("Parameter is an unevaluated expression")
+
 const (
 	// LetterForUnprecentedString is a special value for Parameters
 	// that have no preceding letter
@@ -48,7 +54,7 @@ func NewCodeParameter(letter, value string, isString, isDriverId bool) (*CodePar
 func NewSimpleCodeParameter(letter string, value interface{}) *CodeParameter {
 	sv, isString := value.(string)
 	if !isString {
-		sv = fmt.Sprintf("%v", value)
+		sv = formatValue(value)
 	}
 	return &CodeParameter{
 		Letter:       letter,
@@ -66,11 +72,82 @@ func (cp CodeParameter) String() string {
 		l = ""
 	}
 	if cp.IsString && !cp.IsExpression {
-		return fmt.Sprintf(`%s"%s"`, l, strings.ReplaceAll(cp.stringValue, `"`, `""`))
+		return fmt.Sprintf(`%s"%s"`, l, escapeString(cp.stringValue))
 	}
 	return fmt.Sprintf("%s%s", l, cp.stringValue)
 }
 
+// Validate checks if the letter of this parameter is a single letter (or LetterForUnprecentedString),
+// if the expression flag matches the value and if parameters that are not strings have a value,
+// e.g. the X in G1 X is rejected
+func (cp CodeParameter) Validate() error {
+	if len(cp.Letter) != 1 || !(cp.Letter == LetterForUnprecentedString ||
+		(cp.Letter[0] >= 'A' && cp.Letter[0] <= 'Z') || (cp.Letter[0] >= 'a' && cp.Letter[0] <= 'z')) {
+		return fmt.Errorf("%w: letter %q", ErrInvalidParameter, cp.Letter)
+	}
+	if !cp.IsString && strings.TrimSpace(cp.stringValue) == "" {
+		return fmt.Errorf("%w: %s has no value", ErrInvalidParameter, cp.Letter)
+	}
+	isEnclosed := strings.HasPrefix(cp.stringValue, "{") && strings.HasSuffix(cp.stringValue, "}")
+	if cp.IsExpression && !isEnclosed {
+		return fmt.Errorf("%w: %s is flagged as expression but not enclosed in braces", ErrInvalidParameter, cp.Letter)
+	}
+	if !cp.IsExpression && !cp.IsString && isEnclosed {
+		return fmt.Errorf("%w: %s is an expression but not flagged as such", ErrInvalidParameter, cp.Letter)
+	}
+	return nil
+}
+
+// escapeString escapes double quotes the way DSF expects them in quoted strings
+func escapeString(s string) string {
+	return strings.ReplaceAll(s, `"`, `""`)
+}
+
+// formatValue converts a parameter value to its canonical text representation.
+// Floats are rendered without trailing zeros and exponent and lists are separated by colons.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case types.DriverId:
+		return v.String()
+	case []float64:
+		s := make([]string, 0, len(v))
+		for _, f := range v {
+			s = append(s, strconv.FormatFloat(f, 'f', -1, 64))
+		}
+		return strings.Join(s, ":")
+	case []int64:
+		s := make([]string, 0, len(v))
+		for _, i := range v {
+			s = append(s, strconv.FormatInt(i, 10))
+		}
+		return strings.Join(s, ":")
+	case []uint64:
+		s := make([]string, 0, len(v))
+		for _, u := range v {
+			s = append(s, strconv.FormatUint(u, 10))
+		}
+		return strings.Join(s, ":")
+	case []int:
+		s := make([]string, 0, len(v))
+		for _, i := range v {
+			s = append(s, strconv.Itoa(i))
+		}
+		return strings.Join(s, ":")
+	case []types.DriverId:
+		s := make([]string, 0, len(v))
+		for _, d := range v {
+			s = append(s, d.String())
+		}
+		return strings.Join(s, ":")
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
 // Clone will create a copy of the this instance
 func (cp *CodeParameter) Clone() *CodeParameter {
 	cpc := *cp
@@ -182,6 +259,72 @@ func (cp *CodeParameter) AsString() string {
 	return cp.stringValue
 }
 
+// GetFloat64 returns the value as float64 accepting both integer and floating point representations
+func (cp *CodeParameter) GetFloat64() (float64, error) {
+	if cp != nil && cp.IsExpression {
+		return 0, ErrUnevaluatedExpression
+	}
+	return cp.AsFloat64()
+}
+
+// GetInt returns the value as int. Floating point values are accepted if they do not have a fractional part.
+func (cp *CodeParameter) GetInt() (int, error) {
+	if cp == nil {
+		return 0, ErrMissingParameter
+	}
+	if cp.IsExpression {
+		return 0, ErrUnevaluatedExpression
+	}
+	switch v := cp.parsedValue.(type) {
+	case int64:
+		return int(v), nil
+	case uint64:
+		if v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("Cannot convert %s parameter to int (value %s of type %T)", cp.Letter, cp.stringValue, cp.parsedValue)
+}
+
+// GetString returns the string value of this parameter
+func (cp *CodeParameter) GetString() (string, error) {
+	if cp == nil {
+		return "", ErrMissingParameter
+	}
+	if cp.IsExpression {
+		return "", ErrUnevaluatedExpression
+	}
+	return cp.stringValue, nil
+}
+
+// GetBool returns the value as bool. Numeric values are true if they are not zero,
+// other values are parsed by strconv.ParseBool().
+func (cp *CodeParameter) GetBool() (bool, error) {
+	if cp == nil {
+		return false, ErrMissingParameter
+	}
+	if cp.IsExpression {
+		return false, ErrUnevaluatedExpression
+	}
+	switch v := cp.parsedValue.(type) {
+	case int64:
+		return v != 0, nil
+	case uint64:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	}
+	b, err := strconv.ParseBool(cp.stringValue)
+	if err != nil {
+		return false, fmt.Errorf("Cannot convert %s parameter to bool (value %s of type %T)", cp.Letter, cp.stringValue, cp.parsedValue)
+	}
+	return b, nil
+}
+
 // AsFloat64Slice converts this parameter to []float64 if it is a numeric type (or slice)
 func (cp *CodeParameter) AsFloat64Slice() ([]float64, error) {
 	if cp == nil {
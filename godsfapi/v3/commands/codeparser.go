@@ -0,0 +1,438 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// unprecedentedStringCodes are M-codes that take the remainder of the line as single string parameter
+var unprecedentedStringCodes = map[int64]bool{
+	23:  true,
+	28:  true,
+	30:  true,
+	32:  true,
+	36:  true,
+	38:  true,
+	117: true,
+}
+
+// ParseError is returned if a code could not be parsed
+type ParseError struct {
+	// Column is the 1-based position of the offending character
+	Column int
+	// Message describes the problem
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("Failed to parse code at column %d: %s", e.Column, e.Message)
+}
+
+// codeParser holds the state while parsing a single line
+type codeParser struct {
+	line string
+	pos  int
+}
+
+// ParseCode parses a single line of G/M/T-code into a Code instance.
+// Comments (both ; and parenthetical), line numbers, checksums as well as quoted
+// string parameters and expressions are supported. The checksum is not validated.
+// Meta G-code keywords such as if, echo or abort are recognized as well and their
+// argument is stored unparsed in KeywordArgument.
+// The resulting code is using the default channel.
+func ParseCode(line string) (*Code, error) {
+	p := &codeParser{line: strings.TrimRight(line, "\r\n")}
+	c := NewCode()
+
+	// Indentation
+	for p.pos < len(p.line) && (p.line[p.pos] == ' ' || p.line[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos > 255 {
+		return nil, p.errorf("Indentation too deep")
+	}
+	c.Indent = byte(p.pos)
+
+	if err := p.parseLineNumber(c); err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+	if ok, err := p.parseKeyword(c); ok || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	// Code letter and number
+	for {
+		p.skipWhitespace()
+		if p.eol() {
+			break
+		}
+		ch := upper(p.peek())
+		if ch != 'G' && ch != 'M' && ch != 'T' {
+			break
+		}
+		if c.Type != Comment {
+			return nil, p.errorf("Unexpected second code %c", p.peek())
+		}
+		if err := p.parseCommand(c); err != nil {
+			return nil, err
+		}
+		// G53 may prefix another code
+		if c.Type == GCode && c.IsMajorNumber(53) && c.MinorNumber == nil && !c.HasFlag(EnforceAbsolutePosition) {
+			save := p.pos
+			p.skipWhitespace()
+			if !p.eol() && upper(p.peek()) == 'G' {
+				c.Flags |= EnforceAbsolutePosition
+				c.Type = Comment
+				c.MajorNumber = nil
+				continue
+			}
+			p.pos = save
+		}
+		break
+	}
+
+	// Parameters and comments
+	for {
+		p.skipWhitespace()
+		if p.eol() {
+			break
+		}
+		start := p.pos
+		switch ch := p.peek(); {
+		case ch == ';':
+			appendComment(c, p.line[p.pos+1:])
+			p.pos = len(p.line)
+		case ch == '(':
+			end := strings.IndexByte(p.line[p.pos:], ')')
+			if end < 0 {
+				return nil, p.errorf("Unterminated comment")
+			}
+			appendComment(c, p.line[p.pos+1:p.pos+end])
+			p.pos += end + 1
+		case ch == '*':
+			if err := p.parseChecksum(); err != nil {
+				return nil, err
+			}
+		case c.Type == Comment:
+			return nil, p.errorf("Unexpected character %c", ch)
+		case c.Type == MCode && c.MajorNumber != nil && unprecedentedStringCodes[*c.MajorNumber]:
+			if err := p.parseUnprecedentedString(c); err != nil {
+				return nil, err
+			}
+		default:
+			letter := LetterForUnprecentedString
+			if isLetter(ch) {
+				letter = string(upper(ch))
+				p.pos++
+			}
+			value, isString, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if letter != LetterForUnprecentedString && c.HasParameter(letter) {
+				p.pos = start
+				return nil, p.errorf("Duplicate %s parameter", letter)
+			}
+			cp, err := NewCodeParameter(letter, value, isString, false)
+			if err != nil {
+				p.pos = start
+				return nil, p.errorf("%s", err.Error())
+			}
+			c.Parameters = append(c.Parameters, *cp)
+		}
+	}
+
+	if c.Type == Comment && c.Comment == "" && c.HasFlag(EnforceAbsolutePosition) {
+		return nil, p.errorf("Missing code after G53")
+	}
+	return c, nil
+}
+
+// parseLineNumber parses an optional line number such as N123
+func (p *codeParser) parseLineNumber(c *Code) error {
+	if p.eol() || upper(p.peek()) != 'N' {
+		return nil
+	}
+	p.pos++
+	start := p.pos
+	for !p.eol() && isDigit(p.peek()) {
+		p.pos++
+	}
+	if start == p.pos {
+		return p.errorf("Missing line number")
+	}
+	n, err := strconv.ParseInt(p.line[start:p.pos], 10, 64)
+	if err != nil {
+		p.pos = start
+		return p.errorf("Invalid line number")
+	}
+	c.LineNumber = &n
+	return nil
+}
+
+// parseKeyword parses a meta G-code keyword with its argument and a trailing comment. It returns
+// false if the line does not start with a keyword.
+func (p *codeParser) parseKeyword(c *Code) (bool, error) {
+	end := p.pos
+	for end < len(p.line) && p.line[end] >= 'a' && p.line[end] <= 'z' {
+		end++
+	}
+	k, ok := keywords[p.line[p.pos:end]]
+	if !ok || (end < len(p.line) && !isSeparator(p.line[end])) {
+		return false, nil
+	}
+	p.pos = end
+	p.skipWhitespace()
+
+	// The argument extends up to a comment outside of strings and expressions
+	start := p.pos
+	depth := 0
+	inString := false
+	for !p.eol() {
+		ch := p.peek()
+		if ch == '"' {
+			inString = !inString
+		} else if !inString {
+			if ch == '{' {
+				depth++
+			} else if ch == '}' {
+				depth--
+			} else if ch == ';' && depth <= 0 {
+				appendComment(c, p.line[p.pos+1:])
+				break
+			}
+		}
+		p.pos++
+	}
+	if inString {
+		p.pos = start
+		return false, p.errorf("Unterminated string")
+	}
+	argument := strings.TrimRight(p.line[start:p.pos], " \t")
+	if err := k.validateArgument(argument); err != nil {
+		p.pos = start
+		return false, p.errorf("%s", err.Error())
+	}
+	c.Type = KeywordCode
+	c.Keyword = k
+	c.KeywordArgument = argument
+	return true, nil
+}
+
+// parseCommand parses the code type as well as major and minor number
+func (p *codeParser) parseCommand(c *Code) error {
+	letter := upper(p.peek())
+	p.pos++
+	start := p.pos
+	if letter == 'T' && !p.eol() && p.peek() == '-' {
+		p.pos++
+	}
+	for !p.eol() && isDigit(p.peek()) {
+		p.pos++
+	}
+	switch letter {
+	case 'G':
+		c.Type = GCode
+	case 'M':
+		c.Type = MCode
+	case 'T':
+		c.Type = TCode
+	}
+	if start == p.pos {
+		// T without number to query the current tool or T{expression}
+		if letter == 'T' {
+			return nil
+		}
+		return p.errorf("Missing major number")
+	}
+	major, err := strconv.ParseInt(p.line[start:p.pos], 10, 64)
+	if err != nil {
+		p.pos = start
+		return p.errorf("Invalid major number")
+	}
+	c.MajorNumber = &major
+
+	if letter != 'T' && !p.eol() && p.peek() == '.' {
+		p.pos++
+		start = p.pos
+		for !p.eol() && isDigit(p.peek()) {
+			p.pos++
+		}
+		minor, err := strconv.ParseInt(p.line[start:p.pos], 10, 8)
+		if err != nil {
+			p.pos = start
+			return p.errorf("Invalid minor number")
+		}
+		m := int8(minor)
+		c.MinorNumber = &m
+	}
+	if !p.eol() && !isSeparator(p.peek()) && !isLetter(p.peek()) {
+		return p.errorf("Unexpected character %c", p.peek())
+	}
+	return nil
+}
+
+// parseChecksum skips a checksum which has to be the last item on the line
+func (p *codeParser) parseChecksum() error {
+	p.pos++
+	start := p.pos
+	for !p.eol() && isDigit(p.peek()) {
+		p.pos++
+	}
+	if start == p.pos {
+		return p.errorf("Missing checksum")
+	}
+	p.skipWhitespace()
+	if !p.eol() && p.peek() != ';' {
+		return p.errorf("Unexpected character %c after checksum", p.peek())
+	}
+	return nil
+}
+
+// parseValue parses a quoted string, an expression or a plain value
+func (p *codeParser) parseValue() (string, bool, error) {
+	if p.eol() {
+		return "", false, nil
+	}
+	switch p.peek() {
+	case '"':
+		s, err := p.parseQuotedString()
+		return s, true, err
+	case '{':
+		s, err := p.parseExpression()
+		return s, false, err
+	}
+	start := p.pos
+	numeric := true
+	for !p.eol() {
+		ch := p.peek()
+		if isSeparator(ch) || (numeric && isLetter(ch)) {
+			break
+		}
+		numeric = numeric && strings.IndexByte("0123456789.+-:", ch) >= 0
+		p.pos++
+	}
+	return p.line[start:p.pos], false, nil
+}
+
+// parseQuotedString parses a string enclosed in double quotes where "" is an escaped quote
+func (p *codeParser) parseQuotedString() (string, error) {
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for !p.eol() {
+		ch := p.peek()
+		p.pos++
+		if ch == '"' {
+			if !p.eol() && p.peek() == '"' {
+				b.WriteByte('"')
+				p.pos++
+				continue
+			}
+			return b.String(), nil
+		}
+		b.WriteByte(ch)
+	}
+	p.pos = start
+	return "", p.errorf("Unterminated string")
+}
+
+// parseExpression parses an expression enclosed in curly braces including nested ones
+func (p *codeParser) parseExpression() (string, error) {
+	start := p.pos
+	depth := 0
+	inString := false
+	for !p.eol() {
+		ch := p.peek()
+		p.pos++
+		switch {
+		case ch == '"':
+			inString = !inString
+		case inString:
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+			if depth == 0 {
+				return p.line[start:p.pos], nil
+			}
+		}
+	}
+	p.pos = start
+	return "", p.errorf("Unterminated expression")
+}
+
+// parseUnprecedentedString takes the remainder of the line up to a comment as string parameter
+func (p *codeParser) parseUnprecedentedString(c *Code) error {
+	var value string
+	if p.peek() == '"' {
+		s, err := p.parseQuotedString()
+		if err != nil {
+			return err
+		}
+		value = s
+	} else {
+		end := strings.IndexByte(p.line[p.pos:], ';')
+		if end < 0 {
+			end = len(p.line) - p.pos
+		}
+		value = strings.TrimRight(p.line[p.pos:p.pos+end], " \t")
+		p.pos += len(value)
+	}
+	c.Parameters = append(c.Parameters, *NewSimpleCodeParameter(LetterForUnprecentedString, value))
+	return nil
+}
+
+func (p *codeParser) skipWhitespace() {
+	for !p.eol() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *codeParser) eol() bool {
+	return p.pos >= len(p.line)
+}
+
+func (p *codeParser) peek() byte {
+	return p.line[p.pos]
+}
+
+func (p *codeParser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Column: p.pos + 1, Message: fmt.Sprintf(format, args...)}
+}
+
+// appendComment adds a comment to the code separating it from an existing one by a space
+func appendComment(c *Code, comment string) {
+	if c.Comment != "" {
+		c.Comment += " "
+	}
+	c.Comment += comment
+}
+
+func upper(ch byte) byte {
+	if ch >= 'a' && ch <= 'z' {
+		return ch - 'a' + 'A'
+	}
+	return ch
+}
+
+func isLetter(ch byte) bool {
+	ch = upper(ch)
+	return ch >= 'A' && ch <= 'Z'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isSeparator(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == ';' || ch == '(' || ch == '*'
+}
@@ -6,6 +6,9 @@ type GetFileInfo struct {
 	BaseCommand
 	// Filename of the file to analyse
 	FileName string
+	// ReadThumbnailContent requests the encoded thumbnail images to be transmitted as well.
+	// Otherwise only their metadata is returned.
+	ReadThumbnailContent bool `json:",omitempty"`
 }
 
 // NewGetFileInfo creates a new GetFileInfo for the given file name
@@ -16,6 +19,14 @@ func NewGetFileInfo(fileName string) *GetFileInfo {
 	}
 }
 
+// NewGetFileInfoWithThumbnails creates a new GetFileInfo for the given file name
+// that includes the content of the thumbnails
+func NewGetFileInfoWithThumbnails(fileName string) *GetFileInfo {
+	gfi := NewGetFileInfo(fileName)
+	gfi.ReadThumbnailContent = true
+	return gfi
+}
+
 // ResolvePath will resolve a RepRapFirmware-style path to an actual file system path
 type ResolvePath struct {
 	BaseCommand
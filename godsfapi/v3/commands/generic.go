@@ -26,6 +26,11 @@ func NewEvaluateExpression(channel types.CodeChannel, expression string) *Evalua
 	}
 }
 
+// Validate checks if the channel is valid
+func (ee *EvaluateExpression) Validate() error {
+	return ee.Channel.Validate()
+}
+
 // Flush waits for all pending (macro) codes on the given channel to finish.
 // This effectively guarantees that all buffered codes are processed by RRF
 // before this command finishes.
@@ -46,6 +51,32 @@ func NewFlush(channel types.CodeChannel) *Flush {
 	}
 }
 
+// Validate checks if the channel is valid
+func (f *Flush) Validate() error {
+	return f.Channel.Validate()
+}
+
+// InvalidateChannel cancels all pending codes and files on the given channel
+// including the codes that are already buffered by RepRapFirmware
+type InvalidateChannel struct {
+	BaseCommand
+	// Channel is the CodeChannel to invalidate
+	Channel types.CodeChannel
+}
+
+// NewInvalidateChannel creates a new InvalidateChannel command for the given CodeChannel
+func NewInvalidateChannel(channel types.CodeChannel) *InvalidateChannel {
+	return &InvalidateChannel{
+		BaseCommand: *NewBaseCommand("InvalidateChannel"),
+		Channel:     channel,
+	}
+}
+
+// Validate checks if the channel is valid
+func (ic *InvalidateChannel) Validate() error {
+	return ic.Channel.Validate()
+}
+
 // SetUpdateStatus overrides the current status as reported by the object model
 // when performing a software update
 type SetUpdateStatus struct {
@@ -85,6 +116,25 @@ func NewSimpleCode(code string, channel types.CodeChannel) *SimpleCode {
 	}
 }
 
+// Validate checks if the channel is valid
+func (sc *SimpleCode) Validate() error {
+	return sc.Channel.Validate()
+}
+
+// LogLevel is the minimum severity of messages written to the log file
+type LogLevel string
+
+const (
+	// LogLevelDebug logs all messages
+	LogLevelDebug LogLevel = "debug"
+	// LogLevelInfo logs info, warning and error messages
+	LogLevelInfo LogLevel = "info"
+	// LogLevelWarn logs only warning and error messages
+	LogLevelWarn LogLevel = "warn"
+	// LogLevelOff does not log anything
+	LogLevelOff LogLevel = "off"
+)
+
 // WriteMessage writes an arbitrary generic message.
 // If neither OutputMessage nor LogMessage is true the message is
 // written to the console output.
@@ -97,7 +147,11 @@ type WriteMessage struct {
 	// OutputMessage on the console and via the object model
 	OutputMessage bool
 	// LogMessage writes the message to the log file (if applicable)
+	// Deprecated: Use LogLevel instead
 	LogMessage bool
+	// LogLevel of the message to write to the log file. The message is not logged if this is
+	// LogLevelOff or empty unless LogMessage is set
+	LogLevel LogLevel `json:",omitempty"`
 }
 
 // NewWriteMessage creates a new WriteMessage
@@ -110,3 +164,14 @@ func NewWriteMessage(mType messages.MessageType, content string, outputMessage,
 		LogMessage:    logMessage,
 	}
 }
+
+// NewWriteMessageWithLogLevel creates a new WriteMessage that is logged with the given level
+func NewWriteMessageWithLogLevel(mType messages.MessageType, content string, outputMessage bool, logLevel LogLevel) *WriteMessage {
+	return &WriteMessage{
+		BaseCommand:   *NewBaseCommand("WriteMessage"),
+		Type:          mType,
+		Content:       content,
+		OutputMessage: outputMessage,
+		LogLevel:      logLevel,
+	}
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/network"
+)
+
+// networkProtocolNumbers maps network protocols to the P parameter of M586
+var networkProtocolNumbers = map[network.NetworkProtocol]int{
+	network.HTTP:   0,
+	network.HTTPS:  0,
+	network.FTP:    1,
+	network.SFTP:   1,
+	network.Telnet: 2,
+}
+
+// NewSetNetworkProtocol creates a code to enable or disable a network protocol.
+// The control server has no dedicated command for this so the equivalent M586 code is returned.
+// port is optional and the default port of the protocol is used if it is 0.
+// HTTPS and SFTP are the secure variants of HTTP and FTP and imply secure.
+func NewSetNetworkProtocol(protocol network.NetworkProtocol, enabled bool, port int, secure bool) (*Code, error) {
+	number, ok := networkProtocolNumbers[protocol]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported network protocol %s", protocol)
+	}
+	if protocol == network.HTTPS || protocol == network.SFTP {
+		secure = true
+	}
+	if secure && protocol == network.Telnet {
+		return nil, fmt.Errorf("Network protocol %s cannot be secured", protocol)
+	}
+	if port < 0 || port > 65535 {
+		return nil, fmt.Errorf("Invalid port %d", port)
+	}
+
+	s := 0
+	if enabled {
+		s = 1
+	}
+	cb := NewCodeBuilder().M(586).Param("P", number).Param("S", s)
+	if port > 0 {
+		cb.Param("R", port)
+	}
+	if secure {
+		cb.Param("T", 1)
+	}
+	return cb.Build()
+}
@@ -10,6 +10,21 @@ func NewGetObjectModel() *BaseCommand {
 	return getObjectModel
 }
 
+// GetObjectModelKey requests a single top-level key of the object model
+type GetObjectModelKey struct {
+	BaseCommand
+	// Key of the object model to query, e.g. "job" or "heat"
+	Key string
+}
+
+// NewGetObjectModelKey creates a new GetObjectModel command for the given key
+func NewGetObjectModelKey(key string) *GetObjectModelKey {
+	return &GetObjectModelKey{
+		BaseCommand: *NewBaseCommand("GetObjectModel"),
+		Key:         key,
+	}
+}
+
 // NewSyncObjectModel returns a SyncObjectModel command
 func NewSyncObjectModel() *BaseCommand {
 	return syncObjectModel
@@ -1,5 +1,7 @@
 package commands
 
+import "encoding/json"
+
 // InstallPlugin is used to install or upgrade a plugin
 type InstallPlugin struct {
 	BaseCommand
@@ -15,6 +17,22 @@ func NewInstallPlugin(pluginFile string) *InstallPlugin {
 	}
 }
 
+// InstallSystemPackage is used to install or upgrade a system package, e.g. a dependency of a plugin.
+// This is supported by DSF 3.4 and newer.
+type InstallSystemPackage struct {
+	BaseCommand
+	// PackageFile is the absolute file path to the package file (e.g. a .deb file)
+	PackageFile string
+}
+
+// NewInstallSystemPackage creates a new InstallSystemPackage instance for the given package file
+func NewInstallSystemPackage(packageFile string) *InstallSystemPackage {
+	return &InstallSystemPackage{
+		BaseCommand: *NewBaseCommand("InstallSystemPackage"),
+		PackageFile: packageFile,
+	}
+}
+
 // PluginControl is used to start/stop/uninstall plugins
 type PluginControl struct {
 	BaseCommand
@@ -38,6 +56,15 @@ func NewStopPlugin(plugin string) *PluginControl {
 	}
 }
 
+// NewReloadPlugin creates a new command to reload the manifest of the given plugin from disk.
+// This is supported by DSF 3.3 and newer. It does not restart the plugin process.
+func NewReloadPlugin(plugin string) *PluginControl {
+	return &PluginControl{
+		BaseCommand: *NewBaseCommand("ReloadPlugin"),
+		Plugin:      plugin,
+	}
+}
+
 // NewUninstallPlugin creates a new uninstall command for the given plugin
 func NewUninstallPlugin(plugin string) *PluginControl {
 	return &PluginControl{
@@ -59,7 +86,8 @@ type SetPluginData struct {
 	Value string
 }
 
-// New SetPluginData creates a new command to set plugin data
+// NewSetPluginData creates a new command to set plugin data.
+// The value is stored as plain string, use NewSetPluginDataJSON to store a JSON node instead.
 func NewSetPluginData(plugin, key, value string) *SetPluginData {
 	return &SetPluginData{
 		BaseCommand: *NewBaseCommand("SetPluginData"),
@@ -68,3 +96,31 @@ func NewSetPluginData(plugin, key, value string) *SetPluginData {
 		Value:       value,
 	}
 }
+
+// SetPluginDataJSON sets custom plugin data in the object model to a JSON value.
+// It is sent as SetPluginData command but unlike SetPluginData the value is transmitted as JSON node
+// so objects, arrays, numbers and booleans are stored as such instead of as quoted string.
+type SetPluginDataJSON struct {
+	BaseCommand
+	// Plugin is the name of the plugin
+	Plugin string
+	// Key to set
+	Key string
+	// Value to set
+	Value json.RawMessage
+}
+
+// NewSetPluginDataJSON creates a new command to set plugin data to the JSON representation of value.
+// The value is stored as JSON node, e.g. a map becomes an object in the object model.
+func NewSetPluginDataJSON(plugin, key string, value interface{}) (*SetPluginDataJSON, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &SetPluginDataJSON{
+		BaseCommand: *NewBaseCommand("SetPluginData"),
+		Plugin:      plugin,
+		Key:         key,
+		Value:       b,
+	}, nil
+}
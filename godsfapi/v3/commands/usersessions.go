@@ -44,3 +44,18 @@ func NewRemoveUserSession(id int) *RemoveUserSession {
 		Id:          id,
 	}
 }
+
+// CheckPassword checks if the given password matches the machine password set via M551
+type CheckPassword struct {
+	BaseCommand
+	// Password to check
+	Password string
+}
+
+// NewCheckPassword creates a new CheckPassword for the given password
+func NewCheckPassword(password string) *CheckPassword {
+	return &CheckPassword{
+		BaseCommand: *NewBaseCommand("CheckPassword"),
+		Password:    password,
+	}
+}
@@ -1,111 +1,450 @@
 package connection
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/httpendpoints"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/job"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/move"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/network"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/plugins"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/usersessions"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
 )
 
+// ErrFlushAborted is returned by FlushChannel if the codes of the channel were cancelled
+var ErrFlushAborted = errors.New("Flush aborted because the code channel was cancelled")
+
+const (
+	// pluginPollInterval is the interval in which StartPluginAndWait checks the plugin
+	pluginPollInterval = 250 * time.Millisecond
+	// modelConfirmTimeout is the time helpers wait for the object model to reflect a change
+	modelConfirmTimeout = 2 * time.Second
+	// modelConfirmPollInterval is the delay between two queries of the object model
+	modelConfirmPollInterval = 100 * time.Millisecond
+)
+
+// ErrNoHeightMap is returned by GetHeightMap if mesh compensation is not in use
+var ErrNoHeightMap = errors.New("No height map loaded")
+
+// ErrPluginInstallInterrupted is returned (wrapped) by InstallPlugin if the connection was lost
+// before the control server confirmed the installation. The plugin may be partially installed then.
+var ErrPluginInstallInterrupted = errors.New("Plugin installation interrupted, the plugin may be partially installed")
+
+// ErrNoSharedFileSystem is returned by functions that access files of the control server directly,
+// e.g. UploadFile and DownloadFile, if the connection was established over the network (see NetworkConnect)
+var ErrNoSharedFileSystem = errors.New("Control server does not share the file system with this client")
+
 // BaseCommandConnection for sending commands to the control server
 type BaseCommandConnection struct {
 	BaseConnection
+	// CachePaths enables caching the results of ResolvePath and ResolvePaths.
+	// The mapping of RepRapFirmware-style paths does not change at runtime unless the
+	// directories are reconfigured (M470 or M471) in which case InvalidatePathCache has to be called.
+	CachePaths bool
+	// pathCacheMu guards pathCache
+	pathCacheMu sync.Mutex
+	pathCache   map[string]string
+	// permissionsMu guards grantedPermissions
+	permissionsMu      sync.Mutex
+	grantedPermissions map[types.SbcPermissions]bool
+	// boardInfoMu guards boardInfo
+	boardInfoMu sync.Mutex
+	boardInfo   *BoardInfo
+	// endpointsMu guards endpoints
+	endpointsMu sync.Mutex
+	// endpoints are the HTTP endpoints created by this connection
+	endpoints []*HttpEndpointUnixSocket
+	// queueMu guards queued
+	queueMu sync.Mutex
+	// queued are the codes sent to the Queue channel by this connection that have not finished yet
+	queued []*commands.Code
+	// invalidateSeparately replaces the second connection used to invalidate a code channel if this
+	// connection was established via ConnectTransport. It is a hook for tests using a mock transport.
+	invalidateSeparately func(channel types.CodeChannel) error
+}
+
+// Close removes all HTTP endpoints created by this connection and closes the connection
+// after waiting for pending commands (see BaseConnection.Close)
+func (bcc *BaseCommandConnection) Close() error {
+	if bcc == nil {
+		return nil
+	}
+	bcc.closeEndpoints(true)
+	return bcc.BaseConnection.Close()
+}
+
+// CloseNow closes the sockets of all HTTP endpoints created by this connection and the connection
+// itself without waiting for pending commands. The endpoints are not removed from the control server.
+func (bcc *BaseCommandConnection) CloseNow() error {
+	if bcc == nil {
+		return nil
+	}
+	bcc.closeEndpoints(false)
+	return bcc.BaseConnection.CloseNow()
+}
+
+// closeEndpoints closes all HTTP endpoints created by this connection and optionally removes them
+func (bcc *BaseCommandConnection) closeEndpoints(remove bool) {
+	bcc.endpointsMu.Lock()
+	endpoints := bcc.endpoints
+	bcc.endpoints = nil
+	bcc.endpointsMu.Unlock()
+	for _, h := range endpoints {
+		if remove {
+			if _, err := bcc.removeHttpEndpoint(h.EndpointType, h.Namespace, h.EndpointPath); err != nil {
+				bcc.logger().Errorf("<Close> Failed to remove HTTP endpoint %s/%s: %v", h.Namespace, h.EndpointPath, err)
+			}
+		}
+		h.Close()
+	}
 }
 
 // AddHttpEndpoint adds a new third-party HTTP endpoint in the format /machine/{ns}/{path}
+// The returned HttpEndpointUnixSocket rejects requests until its Handler is set.
+// Use AddHttpEndpointHandler to avoid that.
 func (bcc *BaseCommandConnection) AddHttpEndpoint(t httpendpoints.HttpEndpointType, ns, path string, isUploadRequest bool, backlog uint64) (*HttpEndpointUnixSocket, error) {
-	r, err := bcc.PerformCommand(commands.NewAddHttpEndpoint(t, ns, path, isUploadRequest))
+	return bcc.AddHttpEndpointHandler(t, ns, path, isUploadRequest, nil)
+}
+
+// AddHttpEndpointHandler adds a new third-party HTTP endpoint in the format /machine/{ns}/{path}
+// whose requests are passed to the given handler. See NewHttpHandler to use a standard http.Handler.
+// Endpoints are removed again by HttpEndpointUnixSocket.Unregister, RemoveHttpEndpoint or once
+// this connection is closed.
+func (bcc *BaseCommandConnection) AddHttpEndpointHandler(t httpendpoints.HttpEndpointType, ns, path string, isUploadRequest bool, handler HttpEndpointHandler) (*HttpEndpointUnixSocket, error) {
+	var socketPath string
+	_, err := bcc.performCommand(context.Background(), commands.NewAddHttpEndpoint(t, ns, path, isUploadRequest), &socketPath)
 	if err != nil {
 		return nil, err
 	}
-	socketPath := r.GetResult().(string)
-	if backlog <= 0 {
-		backlog = DefaultBacklog
-	}
-	heus, err := NewHttpEndpointUnixSocket(t, ns, path, socketPath, backlog)
+	h, err := newHttpEndpointUnixSocket(&HttpEndpointUnixSocket{
+		EndpointType:    t,
+		Namespace:       ns,
+		EndpointPath:    path,
+		SocketPath:      socketPath,
+		Handler:         handler,
+		IsUploadRequest: isUploadRequest,
+		owner:           bcc,
+	})
 	if err != nil {
+		bcc.removeHttpEndpoint(t, ns, path)
 		return nil, err
 	}
-	return heus, nil
+	bcc.endpointsMu.Lock()
+	bcc.endpoints = append(bcc.endpoints, h)
+	bcc.endpointsMu.Unlock()
+	return h, nil
 }
 
-// AddUserSession adds a new user session. Pass -1 as originPort to have it replaced by current PID.
+// AddUserSession adds a new user session and returns its ID. Pass -1 as originPort to have it replaced by current PID.
 func (bcc *BaseCommandConnection) AddUserSession(access usersessions.AccessLevel, t usersessions.SessionType, origin string, originPort int) (int, error) {
+	if !access.IsValid() {
+		return -1, fmt.Errorf("Invalid access level %s", access)
+	}
+	if !t.IsValid() {
+		return -1, fmt.Errorf("Invalid session type %s", t)
+	}
 	if originPort == -1 {
 		originPort = os.Getpid()
 	}
-	r, err := bcc.PerformCommand(commands.NewAddUserSession(access, t, origin, originPort))
+	var id int
+	_, err := bcc.performCommand(context.Background(), commands.NewAddUserSession(access, t, origin, originPort), &id)
 	if err != nil {
 		return -1, err
 	}
-	return r.GetResult().(int), nil
+	return id, nil
 }
 
-// RemoveHttpEndpoint removes an existing HTTP endpoint
+// RemoveHttpEndpoint removes an existing HTTP endpoint. If it was created by this
+// connection its UNIX socket is closed as well.
 func (bcc *BaseCommandConnection) RemoveHttpEndpoint(t httpendpoints.HttpEndpointType, ns, path string) (bool, error) {
-	r, err := bcc.PerformCommand(commands.NewRemoveHttpEndpoint(t, ns, path))
+	bcc.endpointsMu.Lock()
+	for i, h := range bcc.endpoints {
+		if h.EndpointType == t && h.Namespace == ns && h.EndpointPath == path {
+			bcc.endpoints = append(bcc.endpoints[:i], bcc.endpoints[i+1:]...)
+			defer h.Close()
+			break
+		}
+	}
+	bcc.endpointsMu.Unlock()
+	return bcc.removeHttpEndpoint(t, ns, path)
+}
+
+// removeHttpEndpoint sends the command to remove an HTTP endpoint
+func (bcc *BaseCommandConnection) removeHttpEndpoint(t httpendpoints.HttpEndpointType, ns, path string) (bool, error) {
+	var removed bool
+	_, err := bcc.performCommand(context.Background(), commands.NewRemoveHttpEndpoint(t, ns, path), &removed)
 	if err != nil {
 		return false, err
 	}
-	return r.IsSuccess(), nil
+	return removed, nil
 }
 
-// RemoveUserSession removes an existing user session
+// RemoveUserSession removes an existing user session. It returns false if there was no such session.
 func (bcc *BaseCommandConnection) RemoveUserSession(id int) (bool, error) {
-	r, err := bcc.PerformCommand(commands.NewRemoveUserSession(id))
+	var removed bool
+	_, err := bcc.performCommand(context.Background(), commands.NewRemoveUserSession(id), &removed)
 	if err != nil {
 		return false, err
 	}
-	return r.IsSuccess(), nil
+	return removed, nil
 }
 
-// Flush waits for all pending codes of the given channel to finish
+// CheckPassword checks if the given password matches the machine password set via M551.
+// If no password is configured (or it is the default "reprap") every password is accepted,
+// see PasswordRequired to tell these cases apart.
+func (bcc *BaseCommandConnection) CheckPassword(password string) (bool, error) {
+	var matches bool
+	_, err := bcc.performCommand(context.Background(), commands.NewCheckPassword(password), &matches)
+	if err != nil {
+		return false, err
+	}
+	return matches, nil
+}
+
+// PasswordRequired checks if a machine password is configured that clients have to provide.
+// It checks if an empty password is accepted which is the case if no password, an empty one or
+// the default "reprap" is configured. All of these are treated as no password being required.
+func (bcc *BaseCommandConnection) PasswordRequired() (bool, error) {
+	matches, err := bcc.CheckPassword("")
+	if err != nil {
+		return false, err
+	}
+	return !matches, nil
+}
+
+// SetNetworkProtocol enables or disables a network protocol (see commands.NewSetNetworkProtocol)
+func (bcc *BaseCommandConnection) SetNetworkProtocol(protocol network.NetworkProtocol, enabled bool, port int, secure bool) error {
+	c, err := commands.NewSetNetworkProtocol(protocol, enabled, port, secure)
+	if err != nil {
+		return err
+	}
+	return bcc.performCodeChecked(context.Background(), c)
+}
+
+// Flush waits for all pending codes of the given channel to finish.
+// It returns false if the codes of the channel were cancelled instead.
 func (bcc *BaseCommandConnection) Flush(channel types.CodeChannel) (bool, error) {
-	r, err := bcc.PerformCommand(commands.NewFlush(channel))
+	return bcc.FlushCtx(context.Background(), channel)
+}
+
+// FlushCtx waits for all pending codes of the given channel to finish or until ctx is done.
+// It returns false if the codes of the channel were cancelled instead.
+func (bcc *BaseCommandConnection) FlushCtx(ctx context.Context, channel types.CodeChannel) (bool, error) {
+	var flushed bool
+	_, err := bcc.performCommand(ctx, commands.NewFlush(channel), &flushed)
 	if err != nil {
 		return false, err
 	}
-	return r.IsSuccess(), nil
+	return flushed, nil
+}
+
+// FlushChannel waits for all pending codes of the given channel to finish.
+// ErrFlushAborted is returned if the codes were cancelled instead, e.g. by an emergency stop.
+func (bcc *BaseCommandConnection) FlushChannel(channel types.CodeChannel) error {
+	flushed, err := bcc.Flush(channel)
+	if err != nil {
+		return err
+	}
+	if !flushed {
+		return ErrFlushAborted
+	}
+	return nil
+}
+
+// InvalidateChannel cancels all pending codes and files of the given channel.
+// Keep in mind that the control server processes the commands of a connection in order, so
+// this has to be sent on a different connection than the codes that are supposed to be cancelled.
+func (bcc *BaseCommandConnection) InvalidateChannel(channel types.CodeChannel) error {
+	_, err := bcc.PerformCommand(commands.NewInvalidateChannel(channel))
+	return err
+}
+
+// EvaluateExpression evaluates an arbitrary expression on the given channel and returns its result
+func (bcc *BaseCommandConnection) EvaluateExpression(channel types.CodeChannel, expression string) (interface{}, error) {
+	return bcc.EvaluateExpressionCtx(context.Background(), channel, expression)
+}
+
+// EvaluateExpressionCtx evaluates an arbitrary expression on the given channel and returns its result
+// or stops waiting for it once ctx is done
+func (bcc *BaseCommandConnection) EvaluateExpressionCtx(ctx context.Context, channel types.CodeChannel, expression string) (interface{}, error) {
+	r, err := bcc.PerformCommandCtx(ctx, commands.NewEvaluateExpression(channel, expression))
+	if err != nil {
+		return nil, err
+	}
+	return r.GetResult(), nil
+}
+
+// EvaluateExpressionAsFloat64 evaluates an expression and returns its result as float64
+func (bcc *BaseCommandConnection) EvaluateExpressionAsFloat64(channel types.CodeChannel, expression string) (float64, error) {
+	r, err := bcc.EvaluateExpression(channel, expression)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := r.(float64)
+	if !ok {
+		return 0, conversionError(expression, r, "float64")
+	}
+	return f, nil
+}
+
+// EvaluateExpressionAsInt evaluates an expression and returns its result as int.
+// Results with a fractional part are rejected.
+func (bcc *BaseCommandConnection) EvaluateExpressionAsInt(channel types.CodeChannel, expression string) (int, error) {
+	r, err := bcc.EvaluateExpression(channel, expression)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := r.(float64)
+	if !ok || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, conversionError(expression, r, "int")
+	}
+	return int(f), nil
+}
+
+// EvaluateExpressionAsBool evaluates an expression and returns its result as bool
+func (bcc *BaseCommandConnection) EvaluateExpressionAsBool(channel types.CodeChannel, expression string) (bool, error) {
+	r, err := bcc.EvaluateExpression(channel, expression)
+	if err != nil {
+		return false, err
+	}
+	b, ok := r.(bool)
+	if !ok {
+		return false, conversionError(expression, r, "bool")
+	}
+	return b, nil
+}
+
+// EvaluateExpressionAsString evaluates an expression and returns its result as string
+func (bcc *BaseCommandConnection) EvaluateExpressionAsString(channel types.CodeChannel, expression string) (string, error) {
+	r, err := bcc.EvaluateExpression(channel, expression)
+	if err != nil {
+		return "", err
+	}
+	str, ok := r.(string)
+	if !ok {
+		return "", conversionError(expression, r, "string")
+	}
+	return str, nil
+}
+
+// conversionError creates an error for an expression result that cannot be represented as target type
+func conversionError(expression string, result interface{}, target string) error {
+	return fmt.Errorf("Result of expression %s (%T %v) cannot be represented as %s", expression, result, result, target)
 }
 
 // GetFileInfo gets the parsed G-code file information
 func (bcc *BaseCommandConnection) GetFileInfo(fileName string) (*job.ParsedFileInfo, error) {
-	r, err := bcc.PerformCommand(commands.NewGetFileInfo(fileName))
+	return bcc.GetFileInfoCtx(context.Background(), fileName)
+}
+
+// GetFileInfoCtx gets the parsed G-code file information or stops waiting for it once ctx is done
+func (bcc *BaseCommandConnection) GetFileInfoCtx(ctx context.Context, fileName string) (*job.ParsedFileInfo, error) {
+	return bcc.getFileInfo(ctx, commands.NewGetFileInfo(fileName))
+}
+
+// GetFileInfoWithThumbnails gets the parsed G-code file information including the
+// encoded thumbnail images. See job.Thumbnail.Image to decode them.
+func (bcc *BaseCommandConnection) GetFileInfoWithThumbnails(fileName string) (*job.ParsedFileInfo, error) {
+	return bcc.getFileInfo(context.Background(), commands.NewGetFileInfoWithThumbnails(fileName))
+}
+
+// getFileInfo performs the given GetFileInfo command
+func (bcc *BaseCommandConnection) getFileInfo(ctx context.Context, gfi *commands.GetFileInfo) (*job.ParsedFileInfo, error) {
+	pfi := &job.ParsedFileInfo{}
+	_, err := bcc.performCommand(ctx, gfi, pfi)
+	if err != nil {
+		return nil, err
+	}
+	return pfi, nil
+}
+
+// GetHeightMap reads the height map that is currently used for mesh compensation.
+// The file is read from the local file system so this only works on the SBC itself.
+// ErrNoHeightMap is returned if no height map is loaded.
+func (bcc *BaseCommandConnection) GetHeightMap() (*move.HeightMap, error) {
+	var m move.Move
+	err := bcc.GetObjectModelKey("move", &m)
+	if err != nil {
+		return nil, err
+	}
+	comp := m.Compensation
+	if comp.Type != move.Mesh || comp.File == "" {
+		return nil, ErrNoHeightMap
+	}
+	if err = bcc.requireSharedFileSystem("read height map"); err != nil {
+		return nil, err
+	}
+	path, err := bcc.ResolvePath(comp.File)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	pfi := r.GetResult().(job.ParsedFileInfo)
-	return &pfi, nil
+	defer f.Close()
+	return move.ParseHeightMap(f)
 }
 
 // PerformCode executes an arbitrary pre-parsed code
 // Note that even with an error being nil the returned *commands.CodeResult
 // can also be nil, e.g. when sending Asynchronous commands that will only be queued and have no result yet.
 func (bcc *BaseCommandConnection) PerformCode(code *commands.Code) (*commands.CodeResult, error) {
-	r, err := bcc.PerformCommand(code)
+	return bcc.PerformCodeCtx(context.Background(), code)
+}
+
+// PerformCodeCtx executes an arbitrary pre-parsed code and stops waiting for its result once ctx is done.
+// The code itself will still be executed by the control server in that case.
+func (bcc *BaseCommandConnection) PerformCodeCtx(ctx context.Context, code *commands.Code) (*commands.CodeResult, error) {
+	r, err := bcc.sendCode(code)
+	if err != nil {
+		return nil, err
+	}
+	var cr commands.CodeResult
+	_, err = bcc.awaitCommand(ctx, r, code, &cr)
 	if err != nil {
 		return nil, err
 	}
-	if r.GetResult() != nil {
-		cr := r.GetResult().(commands.CodeResult)
-		return &cr, nil
+	return codeResult(cr), nil
+}
+
+// codeResult returns a pointer to cr or nil if there is no result
+func codeResult(cr commands.CodeResult) *commands.CodeResult {
+	if cr == nil {
+		return nil
 	}
-	return nil, nil
+	return &cr
 }
 
 // PerformSimpleCode executes an arbitrary G/M/T-code in text form and returns the result as a string
 func (bcc *BaseCommandConnection) PerformSimpleCode(code string, channel types.CodeChannel) (string, error) {
-	r, err := bcc.PerformCommand(commands.NewSimpleCode(code, channel))
+	return bcc.PerformSimpleCodeCtx(context.Background(), code, channel)
+}
+
+// PerformSimpleCodeCtx executes an arbitrary G/M/T-code in text form and returns the result as a string.
+// It stops waiting for the result once ctx is done but the code will still be executed by the control server.
+func (bcc *BaseCommandConnection) PerformSimpleCodeCtx(ctx context.Context, code string, channel types.CodeChannel) (string, error) {
+	var result string
+	_, err := bcc.performCommand(ctx, commands.NewSimpleCode(code, channel), &result)
 	if err != nil {
 		return "", err
 	}
-	return r.GetResult().(string), nil
+	return result, nil
 }
 
 // GetMachineModel retrieves the full object model of the machine.
@@ -120,12 +459,56 @@ func (bcc *BaseCommandConnection) GetMachineModel() (*machine.MachineModel, erro
 // In subscription mode this is the first command that has to be called once a connection has
 // been established
 func (bcc *BaseCommandConnection) GetObjectModel() (*machine.MachineModel, error) {
-	r, err := bcc.PerformCommand(commands.NewGetObjectModel())
+	return bcc.GetObjectModelCtx(context.Background())
+}
+
+// GetObjectModelCtx retrieves the full object model of the machine or stops waiting for it once ctx is done
+func (bcc *BaseCommandConnection) GetObjectModelCtx(ctx context.Context) (*machine.MachineModel, error) {
+	mm := machine.NewMachineModel()
+	_, err := bcc.performCommand(ctx, commands.NewGetObjectModel(), mm)
 	if err != nil {
 		return nil, err
 	}
-	mm := r.GetResult().(machine.MachineModel)
-	return &mm, nil
+	return mm, nil
+}
+
+// GetObjectModelKey fetches a single top-level key of the object model such as "job" or "heat"
+// and unmarshals it into dest. Only the requested key is decoded which is considerably cheaper than
+// decoding the full model. Control servers that ignore the key still transfer the full model.
+// A *machine.LookupError wrapping machine.ErrKeyNotFound is returned if the key does not exist.
+func (bcc *BaseCommandConnection) GetObjectModelKey(key string, dest interface{}) error {
+	return bcc.GetObjectModelKeyCtx(context.Background(), key, dest)
+}
+
+// GetObjectModelKeyCtx fetches a single top-level key of the object model or stops waiting for it once ctx is done
+func (bcc *BaseCommandConnection) GetObjectModelKeyCtx(ctx context.Context, key string, dest interface{}) error {
+	if key == "" || strings.ContainsAny(key, ".[]") {
+		return &machine.LookupError{Path: key, Segment: key, Err: machine.ErrInvalidPath}
+	}
+	var om map[string]json.RawMessage
+	_, err := bcc.performCommand(ctx, commands.NewGetObjectModelKey(key), &om)
+	if err != nil {
+		return err
+	}
+	raw, ok := om[key]
+	if !ok {
+		return &machine.LookupError{Path: key, Segment: key, Err: machine.ErrKeyNotFound}
+	}
+	if err = json.Unmarshal(raw, dest); err != nil {
+		return &DecodeError{Target: fmt.Sprintf("%T", dest), Err: err}
+	}
+	return nil
+}
+
+// GetObjectModelRaw fetches the undecoded object model. The raw form is authoritative: it also contains
+// fields added by newer firmware versions that are not modelled by machine.MachineModel yet.
+func (bcc *BaseCommandConnection) GetObjectModelRaw() (json.RawMessage, error) {
+	return bcc.GetSerializedObjectModelCtx(context.Background())
+}
+
+// GetObjectModelRawCtx fetches the undecoded object model or stops waiting for it once ctx is done
+func (bcc *BaseCommandConnection) GetObjectModelRawCtx(ctx context.Context) (json.RawMessage, error) {
+	return bcc.GetSerializedObjectModelCtx(ctx)
 }
 
 // GetSerializedMachineModel fetches the machine model as UTF-8 JSON
@@ -136,12 +519,20 @@ func (bcc *BaseCommandConnection) GetSerializedMachineModel() (json.RawMessage,
 
 // GetSerializedObjectModel fetches the object model as UTF-8 JSON
 func (bcc *BaseCommandConnection) GetSerializedObjectModel() (json.RawMessage, error) {
+	return bcc.GetSerializedObjectModelCtx(context.Background())
+}
+
+// GetSerializedObjectModelCtx fetches the object model as UTF-8 JSON or stops waiting for it once ctx is done
+func (bcc *BaseCommandConnection) GetSerializedObjectModelCtx(ctx context.Context) (json.RawMessage, error) {
 	var raw json.RawMessage
-	err := bcc.Send(commands.NewGetObjectModel())
+	r, err := bcc.sendRequest(commands.NewGetObjectModel())
 	if err != nil {
 		return raw, err
 	}
-	err = bcc.Receive(&raw)
+	err = bcc.awaitResponse(ctx, r, &raw)
+	if bcc.Observer != nil {
+		bcc.observe(commands.NewGetObjectModel(), r.sent, err)
+	}
 	if err != nil {
 		return raw, err
 	}
@@ -158,10 +549,78 @@ func (bcc *BaseCommandConnection) LockMachineModel() error {
 // LockObjectModel locks the machine model for read/write Access
 // It is MANDATORY to call UnlockObjectModel when write access has finished
 func (bcc *BaseCommandConnection) LockObjectModel() error {
-	_, err := bcc.PerformCommand(commands.NewLockObjectModel())
+	return bcc.LockObjectModelCtx(context.Background())
+}
+
+// LockObjectModelCtx locks the machine model for read/write Access or stops waiting for the lock
+// once ctx is done. Since the lock may still be acquired afterwards it is recommended to call
+// UnlockObjectModel in case of an error.
+// It is MANDATORY to call UnlockObjectModel when write access has finished
+func (bcc *BaseCommandConnection) LockObjectModelCtx(ctx context.Context) error {
+	_, err := bcc.PerformCommandCtx(ctx, commands.NewLockObjectModel())
 	return err
 }
 
+// WithLockedModel locks the object model, passes the current model to fn and writes all
+// properties modified by fn back via SetObjectModel. The object model is unlocked in any case,
+// even if fn returns an error or panics. Nothing is written if fn returns an error.
+// Only values can be changed this way, structural changes like adding or removing array
+// items are rejected. Use the raw Lock/Set/UnlockObjectModel commands for advanced use.
+func (bcc *BaseCommandConnection) WithLockedModel(fn func(mm *machine.MachineModel) error) (err error) {
+	if err = bcc.LockObjectModel(); err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := bcc.UnlockObjectModel(); err == nil {
+			err = uerr
+		}
+	}()
+
+	original, err := bcc.GetObjectModel()
+	if err != nil {
+		return err
+	}
+	mm := original.Clone()
+	if err = fn(mm); err != nil {
+		return err
+	}
+
+	changes, err := machine.Diff(original, mm)
+	if err != nil {
+		return err
+	}
+	values := make([]string, len(changes))
+	for i, c := range changes {
+		v, err := machine.Lookup(mm, c.Path)
+		if err != nil {
+			return fmt.Errorf("Cannot apply change of %s: %v", c.Path, err)
+		}
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+			return fmt.Errorf("Cannot apply structural change of %s to the object model", c.Path)
+		case reflect.String:
+			values[i] = rv.String()
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			values[i] = string(b)
+		}
+	}
+	for _, c := range changes {
+		if err = machine.ValidateModelPath(c.Path); err != nil {
+			return err
+		}
+	}
+	for i, c := range changes {
+		if _, err = bcc.SetObjectModel(c.Path, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // PatchObjectModel will apply a full patch to the object model. Use with care!
 func (bcc *BaseCommandConnection) PatchObjectModel(key, value string) error {
 	_, err := bcc.PerformCommand(commands.NewPatchObjectModel(key, value))
@@ -177,7 +636,13 @@ func (bcc *BaseCommandConnection) SetMachineModel(path, value string) (bool, err
 
 // SetObjectModel sets a given property to a certain value. Make sure to lock the object
 // model before calling this.
+// The path is checked using machine.ValidateModelPath before anything is sent so an error
+// wrapping machine.ErrInvalidModelPath is returned for properties that cannot be set.
+// Call machine.ValidateModelPath yourself to detect these before locking the object model.
 func (bcc *BaseCommandConnection) SetObjectModel(path, value string) (bool, error) {
+	if err := machine.ValidateModelPath(path); err != nil {
+		return false, err
+	}
 	r, err := bcc.PerformCommand(commands.NewSetObjectModel(path, value))
 	if err != nil {
 		return false, err
@@ -193,7 +658,12 @@ func (bcc *BaseCommandConnection) SyncMachineModel() error {
 
 // SyncObjectModel waits for the full object model to be updated from RepRapFirmware
 func (bcc *BaseCommandConnection) SyncObjectModel() error {
-	_, err := bcc.PerformCommand(commands.NewSyncObjectModel())
+	return bcc.SyncObjectModelCtx(context.Background())
+}
+
+// SyncObjectModelCtx waits for the full object model to be updated from RepRapFirmware or until ctx is done
+func (bcc *BaseCommandConnection) SyncObjectModelCtx(ctx context.Context) error {
+	_, err := bcc.PerformCommandCtx(ctx, commands.NewSyncObjectModel())
 	return err
 }
 
@@ -211,25 +681,192 @@ func (bcc *BaseCommandConnection) UnlockObjectModel() error {
 
 // ResolvePath resolves a RepRapFirmware-style file path to a real file path
 func (bcc *BaseCommandConnection) ResolvePath(path string) (string, error) {
-	r, err := bcc.PerformCommand(commands.NewResolvePath(path))
+	return bcc.ResolvePathCtx(context.Background(), path)
+}
+
+// ResolvePathCtx resolves a RepRapFirmware-style file path to a real file path or stops waiting once ctx is done
+func (bcc *BaseCommandConnection) ResolvePathCtx(ctx context.Context, path string) (string, error) {
+	if resolved, ok := bcc.cachedPath(path); ok {
+		return resolved, nil
+	}
+	var resolved string
+	_, err := bcc.performCommand(ctx, commands.NewResolvePath(path), &resolved)
 	if err != nil {
 		return "", err
 	}
-	return r.GetResult().(string), nil
+	bcc.cachePath(path, resolved)
+	return resolved, nil
 }
 
 // InstallPlugin to install or upgrade a plugin.
-// pluginFile is the absolute file path to the plugin ZIP bundle
+// pluginFile is the absolute file path to the plugin ZIP bundle.
+// The bundle is read by the control server itself so there is no upload whose progress could be reported.
 func (bcc *BaseCommandConnection) InstallPlugin(pluginFile string) error {
 	_, err := bcc.PerformCommand(commands.NewInstallPlugin(pluginFile))
+	if err != nil && isTransportError(err) {
+		return fmt.Errorf("%w: %v", ErrPluginInstallInterrupted, err)
+	}
+	return err
+}
+
+// InstallSystemPackage installs or upgrades a system package and waits until the package manager
+// has finished. packageFile is the absolute file path to the package file. Errors reported by the
+// package manager are returned. Since this requires the SuperUser permission an error wrapping
+// ErrPermissionDenied is returned without contacting the control server if it has not been granted.
+func (bcc *BaseCommandConnection) InstallSystemPackage(packageFile string) error {
+	return bcc.InstallSystemPackageCtx(context.Background(), packageFile)
+}
+
+// InstallSystemPackageCtx installs or upgrades a system package like InstallSystemPackage but stops
+// waiting once ctx is done. The installation itself continues in that case.
+func (bcc *BaseCommandConnection) InstallSystemPackageCtx(ctx context.Context, packageFile string) error {
+	if err := bcc.requirePermission(types.SuperUser); err != nil {
+		return err
+	}
+	_, err := bcc.PerformCommandCtx(ctx, commands.NewInstallSystemPackage(packageFile))
 	return err
 }
 
+// InstallPluginFromReader installs or upgrades a plugin from a ZIP bundle read from r.
+// The control server only accepts plugin bundles from the file system so the content is
+// spooled to a temporary file in its system directory (0:/sys) that is removed again once the
+// installation has finished. This requires the client to share the file system of the control
+// server and to be allowed to write to that directory, so an error wrapping ErrNoSharedFileSystem
+// is returned for connections established via NetworkConnect.
+func (bcc *BaseCommandConnection) InstallPluginFromReader(r io.Reader) error {
+	return bcc.InstallPluginFromReaderProgress(r, -1, nil)
+}
+
+// InstallPluginFromReaderProgress installs or upgrades a plugin like InstallPluginFromReader and reports
+// the number of bytes spooled so far to progress. total is the size of the bundle or -1 if unknown.
+// The bundle is copied in small chunks so large bundles do not have to fit into memory.
+// If reading the bundle fails nothing is installed. progress is optional and may be nil.
+func (bcc *BaseCommandConnection) InstallPluginFromReaderProgress(r io.Reader, total int64, progress ProgressFunc) error {
+	if err := bcc.requireSharedFileSystem("install plugin from reader"); err != nil {
+		return err
+	}
+	// The client's temporary directory may not be visible to DCS, e.g. if it uses PrivateTmp
+	dir, err := bcc.ResolvePath("0:/sys")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".plugin-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	pw := &progressWriter{w: f, total: total, progress: progress}
+	if _, err = io.Copy(pw, r); err != nil {
+		err = fmt.Errorf("Failed to read plugin bundle after %d bytes, nothing has been installed: %w", pw.transferred, err)
+	}
+	if err == nil {
+		// DCS may be running as a different user
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return bcc.InstallPlugin(f.Name())
+}
+
 // SetPluginData sets custom plugin data in the object model
 // plugin is the name of the plugin and is optional. Leave empty if not needed
+// Setting data of another plugin requires the ManagePlugins permission (see CanManagePlugin),
+// otherwise an error wrapping ErrPermissionDenied is returned.
 func (bcc *BaseCommandConnection) SetPluginData(plugin, key, value string) error {
 	_, err := bcc.PerformCommand(commands.NewSetPluginData(plugin, key, value))
-	return err
+	return permissionError(err)
+}
+
+// SetPluginDataJSON sets custom plugin data in the object model to the JSON representation of value.
+// The result is stored as JSON node unlike SetPluginData which always stores a string.
+// Use GetPluginDataJSON to read it back.
+// plugin is the name of the plugin and is optional. Leave empty if not needed
+func (bcc *BaseCommandConnection) SetPluginDataJSON(plugin, key string, value interface{}) error {
+	spd, err := commands.NewSetPluginDataJSON(plugin, key, value)
+	if err != nil {
+		return err
+	}
+	_, err = bcc.PerformCommand(spd)
+	return permissionError(err)
+}
+
+// GetPluginData reads custom plugin data previously stored via SetPluginData.
+// plugin is the name of the plugin and is optional. Leave empty to read the data of the own plugin.
+// Reading data of another plugin requires the ManagePlugins permission (see CanManagePlugin),
+// otherwise an error wrapping ErrPermissionDenied is returned.
+// An error is returned if there is no such plugin or key.
+func (bcc *BaseCommandConnection) GetPluginData(plugin, key string) (string, error) {
+	return bcc.GetPluginDataCtx(context.Background(), plugin, key)
+}
+
+// GetPluginDataCtx reads custom plugin data like GetPluginData or stops waiting for it once ctx is done.
+// Since DCS does not implement GetPluginData the value is taken from the plugins key of the object model.
+func (bcc *BaseCommandConnection) GetPluginDataCtx(ctx context.Context, plugin, key string) (string, error) {
+	v, err := bcc.pluginData(ctx, plugin, key)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// pluginData returns the value of the given plugin data key after checking the permissions
+func (bcc *BaseCommandConnection) pluginData(ctx context.Context, plugin, key string) (interface{}, error) {
+	own, p, err := bcc.ownPlugin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if plugin == "" {
+		if p == nil {
+			return nil, errors.New("Connection does not belong to a plugin")
+		}
+		plugin = own
+	}
+	if p == nil || plugin != own {
+		if err = bcc.requirePermission(types.ManagePlugins); err != nil {
+			return nil, err
+		}
+		var ps map[string]plugins.Plugin
+		if err = bcc.GetObjectModelKeyCtx(ctx, "plugins", &ps); err != nil {
+			return nil, err
+		}
+		other, ok := ps[plugin]
+		if !ok {
+			return nil, fmt.Errorf("Plugin %s not found", plugin)
+		}
+		p = &other
+	}
+	if v, ok := p.Data[key]; ok && v != nil {
+		return v, nil
+	}
+	return nil, fmt.Errorf("Plugin %s has no data with key %s", plugin, key)
+}
+
+// GetPluginDataJSON reads custom plugin data previously stored via SetPluginDataJSON
+// and unmarshals it into v
+func (bcc *BaseCommandConnection) GetPluginDataJSON(plugin, key string, v interface{}) error {
+	return bcc.GetPluginDataJSONCtx(context.Background(), plugin, key, v)
+}
+
+// GetPluginDataJSONCtx reads custom plugin data like GetPluginDataJSON or stops waiting for it once ctx is done
+func (bcc *BaseCommandConnection) GetPluginDataJSONCtx(ctx context.Context, plugin, key string, v interface{}) error {
+	value, err := bcc.pluginData(ctx, plugin, key)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
 }
 
 // StartPlugin starts a plugin
@@ -244,6 +881,77 @@ func (bcc *BaseCommandConnection) StopPlugin(plugin string) error {
 	return err
 }
 
+// ReloadPlugin makes the control server reload the manifest of a plugin from disk, e.g. after
+// its files were replaced during development. The plugin process keeps running; use
+// RestartPlugin to restart it afterwards.
+func (bcc *BaseCommandConnection) ReloadPlugin(plugin string) error {
+	_, err := bcc.PerformCommand(commands.NewReloadPlugin(plugin))
+	return err
+}
+
+// RestartPlugin stops a plugin and starts it again, waiting for its process like StartPluginAndWait.
+// DSF has no native restart so this is a plain stop followed by a start: plugin data in the object
+// model is preserved but HTTP endpoints and other resources of the old process are released when it
+// exits and have to be registered again by the new process.
+func (bcc *BaseCommandConnection) RestartPlugin(ctx context.Context, plugin string) (int64, error) {
+	if _, err := bcc.PerformCommandCtx(ctx, commands.NewStopPlugin(plugin)); err != nil {
+		return 0, err
+	}
+	return bcc.StartPluginAndWait(ctx, plugin)
+}
+
+// StartPluginAndWait starts a plugin and polls the object model until its process is running
+// or ctx is done. The process ID of the plugin is returned. Plugins that do not have an SBC
+// executable are not started as a process so -1 is returned for them right away.
+func (bcc *BaseCommandConnection) StartPluginAndWait(ctx context.Context, plugin string) (int64, error) {
+	if _, err := bcc.PerformCommandCtx(ctx, commands.NewStartPlugin(plugin)); err != nil {
+		return 0, err
+	}
+	for {
+		var ps map[string]plugins.Plugin
+		if err := bcc.GetObjectModelKeyCtx(ctx, "plugins", &ps); err != nil {
+			return 0, err
+		}
+		p, ok := ps[plugin]
+		if !ok {
+			return 0, fmt.Errorf("Plugin %s not found", plugin)
+		}
+		if p.IsStarted() || p.SbcExecutable == "" {
+			return p.Pid, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("Plugin %s has not started yet: %w", plugin, ctx.Err())
+		case <-time.After(pluginPollInterval):
+		}
+	}
+}
+
+// StartPlugins starts the given plugins in order. The result maps the name of every plugin an
+// attempt was made for to the error of its command or nil on success. The first failure stops
+// the sequence so plugins after it are not part of the result and its error is returned as well.
+func (bcc *BaseCommandConnection) StartPlugins(plugins []string) (map[string]error, error) {
+	return bcc.controlPlugins(plugins, bcc.StartPlugin)
+}
+
+// StopPlugins stops the given plugins in order. The result is the same as for StartPlugins.
+func (bcc *BaseCommandConnection) StopPlugins(plugins []string) (map[string]error, error) {
+	return bcc.controlPlugins(plugins, bcc.StopPlugin)
+}
+
+// controlPlugins calls control for each plugin until the first error
+func (bcc *BaseCommandConnection) controlPlugins(plugins []string, control func(plugin string) error) (map[string]error, error) {
+	results := make(map[string]error, len(plugins))
+	for _, plugin := range plugins {
+		err := control(plugin)
+		results[plugin] = err
+		if err != nil {
+			return results, fmt.Errorf("Failed to control plugin %s: %w", plugin, err)
+		}
+	}
+	return results, nil
+}
+
 // UninstallPlugin uninstalls a plugin
 func (bcc *BaseCommandConnection) UninstallPlugin(plugin string) error {
 	_, err := bcc.PerformCommand(commands.NewUninstallPlugin(plugin))
@@ -256,6 +964,13 @@ func (bcc *BaseCommandConnection) WriteTextMessage(mType messages.MessageType, m
 	return err
 }
 
+// WriteMessageWithLogLevel writes an arbitrary generic message that is output on the console
+// if outputMessage is true and logged with the given level
+func (bcc *BaseCommandConnection) WriteMessageWithLogLevel(mType messages.MessageType, message string, outputMessage bool, logLevel commands.LogLevel) error {
+	_, err := bcc.PerformCommand(commands.NewWriteMessageWithLogLevel(mType, message, outputMessage, logLevel))
+	return err
+}
+
 // Write an arbitrary generic message from an existing messages.Message instance
 func (bcc *BaseCommandConnection) WriteMessage(message messages.Message, outputMessage, logMessage bool) error {
 	_, err := bcc.PerformCommand(commands.NewWriteMessage(message.Type, message.Content, outputMessage, logMessage))
@@ -268,3 +983,20 @@ func (bcc *BaseCommandConnection) SetUpdateStatus(updating bool) error {
 	_, err := bcc.PerformCommand(commands.NewSetUpdateStatus(updating))
 	return err
 }
+
+// awaitModel polls check until it returns true or modelConfirmTimeout has elapsed
+func (bcc *BaseCommandConnection) awaitModel(check func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), modelConfirmTimeout)
+	defer cancel()
+	for {
+		ok, err := check()
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Object model does not reflect the change: %w", ctx.Err())
+		case <-time.After(modelConfirmPollInterval):
+		}
+	}
+}
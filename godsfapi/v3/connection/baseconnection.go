@@ -1,19 +1,21 @@
 package connection
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
 )
 
 const (
@@ -21,20 +23,46 @@ const (
 	TaskCanceledException = "TaskCanceledException"
 	// IncompatibleVersionException is the name of a remote exception to be checked for
 	IncompatibleVersionException = "IncompatibleVersionException"
+	// UnauthorizedAccessException is the name of a remote exception thrown for missing permissions
+	UnauthorizedAccessException = "UnauthorizedAccessException"
 	// SocketDirectory is the default directory in which DSF-related UNIX sockets reside
 	SocketDirectory = "/run/dsf"
 	// SocketFile is the default UNIX socket file for DuetControlServer
 	SocketFile = "dcs.sock"
 	// FullSocketPath is the default fully-qualified path to the UNIX socket for DuetControlServer
 	FullSocketPath = SocketDirectory + "/" + SocketFile
+	// DefaultCloseTimeout is the time Close waits for pending commands if CloseTimeout is not set
+	DefaultCloseTimeout = 5 * time.Second
+	// DefaultMaxMessageSize is the maximum size of a message sent if MaxMessageSize is not set
+	DefaultMaxMessageSize = 1 << 20
 )
 
-// DecodeError is returned if a response from DCS could not be unmarshalled
+// ErrMessageTooLarge is returned (wrapped) by Send if a message exceeds MaxMessageSize.
+// Nothing is sent in that case so the connection remains usable.
+var ErrMessageTooLarge = errors.New("Message too large")
+
+// ErrAlreadyConnected is returned by Connect if the connection is already established.
+// Close it first to connect again.
+var ErrAlreadyConnected = errors.New("Connection is already established")
+
+// ErrNotConnected is returned if a connection is used before Connect was called
+var ErrNotConnected = errors.New("Connection has not been established")
+
+// ErrConnectionClosing is returned (wrapped) by commands that were issued after Close was called
+// or that were still pending when the connection was torn down
+var ErrConnectionClosing = errors.New("Connection is closing")
+
+// DecodeError is returned if a response from DCS could not be unmarshalled.
+// It is matched by errors.Is(err, ErrProtocol).
 type DecodeError struct {
 	Target string
 	Err    error
 }
 
+func (e *DecodeError) Kind() error { return ErrProtocol }
+
+func (e *DecodeError) Is(target error) bool { return target == ErrProtocol }
+
 func (e *DecodeError) Unwrap() error { return e.Err }
 
 func (e *DecodeError) Error() string {
@@ -45,6 +73,50 @@ func (e *DecodeError) Error() string {
 	return fmt.Sprintf("Failed to unmarshal to type %s because of %v", e.Target, e.Err)
 }
 
+// ErrCodeCancelled is matched by errors.Is if the control server cancelled a command,
+// e.g. a code whose channel was invalidated or that was pending during an emergency stop
+var ErrCodeCancelled = errors.New("Code has been cancelled")
+
+// cancelledError is returned for commands that failed with a TaskCanceledException
+type cancelledError struct {
+	message string
+}
+
+func (e *cancelledError) Kind() error { return ErrCommand }
+
+func (e *cancelledError) Is(target error) bool {
+	return target == ErrCodeCancelled || target == ErrCommand
+}
+
+func (e *cancelledError) Error() string { return e.message }
+
+// ErrIncompatibleVersion is matched by errors.Is for every IncompatibleVersionError
+var ErrIncompatibleVersion = errors.New("Incompatible API version")
+
+// IncompatibleVersionError is returned on connect if the API versions of this client
+// and the control server do not match. In general this means DSF needs to be upgraded
+// if Server is lower than Client or this library if it is the other way round.
+type IncompatibleVersionError struct {
+	// Client is the API version of this library
+	Client int64
+	// Server is the API version reported by the control server
+	Server int64
+	// Message is the reason given by the control server if it rejected the client
+	Message string
+}
+
+func (e *IncompatibleVersionError) Is(target error) bool { return target == ErrIncompatibleVersion }
+
+func (e *IncompatibleVersionError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("Incompatible API version (client %d, server %d): %s", e.Client, e.Server, e.Message)
+	}
+	return fmt.Sprintf("Incompatible API version (expected %d got %d)", e.Client, e.Server)
+}
+
 var o sync.Once
 var conns []Closer
 
@@ -52,7 +124,7 @@ var conns []Closer
 func CloseOnSignals(c Closer) {
 	o.Do(func() {
 		conns = make([]Closer, 0)
-		sc := make(chan os.Signal)
+		sc := make(chan os.Signal, 1)
 		signal.Notify(sc, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 		go func() {
 			<-sc
@@ -71,35 +143,197 @@ type Closer interface {
 	Close() error
 }
 
+// Observer is notified about the duration and outcome of commands, e.g. to collect metrics
+type Observer interface {
+	// ObserveCommand is called once the response to a command has been received or waiting for it
+	// failed. name is the command name such as Code or GetObjectModel and duration is measured from
+	// sending the command, so it includes waiting for previously sent commands. It is called from the
+	// goroutine waiting for the response and must not block.
+	ObserveCommand(name string, duration time.Duration, err error)
+}
+
+// Dialer establishes the transport to the control server. It is implemented by *net.Dialer.
+type Dialer interface {
+	// Dial connects to the address on the named network
+	Dial(network, address string) (net.Conn, error)
+}
+
 // BaseConnection provides common functionalities for more concrete implementations
 type BaseConnection struct {
-	socket  net.Conn
-	decoder *json.Decoder
-	id      int64
-	Debug   bool
+	transport     ConnectionTransport
+	id            int64
+	serverVersion int64
+	Debug         bool
+	// IndentJSON makes the connection send indented JSON which is easier to read when debugging
+	// via Tap or Debug. Compact JSON is sent by default.
+	IndentJSON bool
+	// MaxMessageSize is the maximum size of a serialized message in bytes. Larger messages are rejected
+	// before they are sent since the control server may fail to process them and desynchronize the
+	// connection. Defaults to DefaultMaxMessageSize if zero, negative values disable the limit.
+	MaxMessageSize int
+	// Dialer used to establish the connection. Defaults to a plain net.Dialer if nil
+	Dialer Dialer
+	// Network used by Connect, e.g. tcp if DCS is reachable via TCP. Defaults to unix if empty.
+	// In that case the socket path passed to Connect is the network address instead, e.g. host:port
+	Network string
+	// DialTimeout limits the time to establish the connection if no custom Dialer is set.
+	// Zero means no timeout.
+	DialTimeout time.Duration
+	// ReadTimeout limits the time to wait for incoming data on each receive. Zero means no timeout.
+	// A timeout is considered a fatal error of the socket so the connection has to be re-established.
+	// Keep in mind that long running codes and idle subscriptions may legitimately take longer.
+	ReadTimeout time.Duration
+	// WriteTimeout limits the time to send data. Zero means no timeout.
+	WriteTimeout time.Duration
+	// CloseTimeout limits the time Close waits for pending commands to receive their responses.
+	// Defaults to DefaultCloseTimeout if not set.
+	CloseTimeout time.Duration
+	// OnDisconnect is called once when a fatal error of the socket is encountered.
+	// It is not called when the connection is closed via Close.
+	OnDisconnect func(err error)
+	// Logger receives diagnostic messages. If nil errors are written to the standard logger
+	// and all other messages only if Debug is set. Use NopLogger to discard all messages.
+	Logger Logger
+	// Observer is notified about every command once it has finished. Nothing is measured if nil.
+	Observer Observer
+	// Tap receives a copy of every raw message sent (prefixed by "> ") and received
+	// (prefixed by "< ") before it is decoded, one message per line. Nothing is redacted.
+	// This is meant for debugging and disabled if nil.
+	Tap io.Writer
+	// tapMu serializes writes to Tap
+	tapMu sync.Mutex
+	// mu guards sending commands and queueing their responses
+	mu sync.Mutex
+	// lastRequest is closed once the most recently sent command received its response
+	lastRequest chan struct{}
+	// permissions are the permissions reported by the control server on connect if any
+	permissions []types.SbcPermissions
+	// initMessage, network and address are kept to be able to re-establish the connection
+	initMessage initmessages.ClientInitMessage
+	network     string
+	address     string
+	// onTransportError is invoked for errors of the given transport which are always a *TransportError
+	// and its return value is passed on to the caller
+	onTransportError func(transport ConnectionTransport, err error) error
+	// stateMu guards the following fields including the counters of Stats
+	stateMu sync.Mutex
+	// live is the currently established transport
+	live         ConnectionTransport
+	closing      bool
+	disconnected bool
+	stats        connectionStats
 }
 
-// Connect establishes a connecton to the given UNIX socket file
+// request represents a command that was sent to the server and is awaiting its response.
+// Responses are received in the same order as the commands were sent.
+type request struct {
+	// prev is closed once the previous request has received its response (nil if there is none)
+	prev chan struct{}
+	// done is closed once this request has received its response
+	done chan struct{}
+	// transport the response has to be read from
+	transport ConnectionTransport
+	// sent is the time the command was sent if an Observer is set
+	sent time.Time
+	// received is called once the response has actually been received, even if nobody waits for it anymore
+	received func()
+}
+
+// Connect establishes a connecton to the given UNIX socket file or
+// to the given address if Network is set
 func (bc *BaseConnection) Connect(initMessage initmessages.ClientInitMessage, socketPath string) error {
-	var err error
-	bc.socket, err = net.Dial("unix", socketPath)
+	network := bc.Network
+	if network == "" {
+		network = "unix"
+	}
+	return bc.connect(initMessage, network, socketPath)
+}
+
+// UnixConnect establishes a connection to the given UNIX socket file
+func (bc *BaseConnection) UnixConnect(initMessage initmessages.ClientInitMessage, socketPath string) error {
+	return bc.connect(initMessage, "unix", socketPath)
+}
+
+// NetworkConnect establishes a TCP connection to the given address (host:port).
+// The protocol is the same as for UNIX sockets.
+func (bc *BaseConnection) NetworkConnect(initMessage initmessages.ClientInitMessage, address string) error {
+	return bc.connect(initMessage, "tcp", address)
+}
+
+// ConnectTransport establishes a connection over an already opened transport, e.g. a mock.Transport
+// in tests. Connections established this way cannot be re-established automatically.
+func (bc *BaseConnection) ConnectTransport(initMessage initmessages.ClientInitMessage, transport ConnectionTransport) error {
+	if err := bc.ensureDisconnected(); err != nil {
+		return err
+	}
+	bc.initMessage = initMessage
+	bc.network = ""
+	bc.address = ""
+	return bc.handshake(transport)
+}
+
+// connect establishes a connection using the given network and address
+func (bc *BaseConnection) connect(initMessage initmessages.ClientInitMessage, network, address string) error {
+	if err := bc.ensureDisconnected(); err != nil {
+		return err
+	}
+	dialer := bc.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{Timeout: bc.DialTimeout}
+	}
+	conn, err := dialer.Dial(network, address)
 	if err != nil {
 		return err
 	}
-	bc.decoder = json.NewDecoder(bc.socket)
+	bc.initMessage = initMessage
+	bc.network = network
+	bc.address = address
+	return bc.handshake(newNetTransport(conn))
+}
+
+// ensureDisconnected returns ErrAlreadyConnected if this connection is established and working.
+// A transport that failed before is closed so it does not leak when connecting again.
+func (bc *BaseConnection) ensureDisconnected() error {
+	bc.stateMu.Lock()
+	live, failed := bc.live, bc.disconnected
+	bc.stateMu.Unlock()
+	if live == nil {
+		return nil
+	}
+	if !failed {
+		return ErrAlreadyConnected
+	}
+	bc.CloseNow()
+	return nil
+}
+
+// handshake exchanges the init messages over the given transport.
+// The transport is closed again if the handshake fails.
+func (bc *BaseConnection) handshake(transport ConnectionTransport) (err error) {
+	bc.transport = transport
+	bc.lastRequest = nil
+	bc.resetState(transport)
+	defer func() {
+		if err != nil {
+			bc.CloseNow()
+		}
+	}()
 
 	sim, err := bc.receiveServerInitMessage()
 	if err != nil {
 		return err
 	}
 
+	bc.serverVersion = sim.Version
 	if !sim.IsCompatible() {
-		return fmt.Errorf("Incompatible API version (expected %d got %d)", initmessages.ProtocolVersion, sim.Version)
+		return &IncompatibleVersionError{Client: initmessages.ProtocolVersion, Server: sim.Version}
 	}
 
 	bc.id = sim.Id
+	bc.permissions = sim.Permissions
+	bc.updateStats(func(s *connectionStats) { s.id, s.serverVersion = sim.Id, sim.Version })
 
-	err = bc.Send(initMessage)
+	err = bc.Send(bc.initMessage)
 	if err != nil {
 		return err
 	}
@@ -110,42 +344,128 @@ func (bc *BaseConnection) Connect(initMessage initmessages.ClientInitMessage, so
 	}
 	if !br.IsSuccess() {
 		if br.GetErrorType() == IncompatibleVersionException {
-			return errors.New(br.GetErrorMessage())
+			return &IncompatibleVersionError{
+				Client:  initmessages.ProtocolVersion,
+				Server:  sim.Version,
+				Message: br.GetErrorMessage(),
+			}
 		}
-		return fmt.Errorf("Could not set connection type %s (%s: %s)", initMessage.GetMode(), br.GetErrorType(), br.GetErrorMessage())
-	}
-	if bc.Debug {
-		log.Println("[DEBUG] <Connect> Connection established")
+		return fmt.Errorf("Could not set connection type %s (%s: %s)", bc.initMessage.GetMode(), br.GetErrorType(), br.GetErrorMessage())
 	}
+	bc.logger().Debugf("<Connect> Connection established")
 	return nil
 }
 
-// Close the UNIX socket connection
+// ServerVersion returns the API version reported by the control server
+// on connect or 0 if no connection has been attempted yet
+func (bc *BaseConnection) ServerVersion() int64 {
+	return bc.serverVersion
+}
+
+// ConnectionId returns the ID the control server assigned to this connection on connect
+func (bc *BaseConnection) ConnectionId() int64 {
+	return bc.id
+}
+
+// Close the UNIX socket connection. New commands are rejected with ErrConnectionClosing right away
+// while commands that have already been sent may receive their responses until CloseTimeout
+// has elapsed. Commands still pending after that fail with an error wrapping ErrConnectionClosing.
 func (bc *BaseConnection) Close() error {
 	if bc == nil {
 		return nil
 	}
-	if bc.socket != nil {
-		if bc.Debug {
-			log.Println("[DEBUG] <Close> Closing connection")
-		}
-		err := bc.socket.Close()
-		if err != nil {
-			log.Println("[ERROR] <Close> Error closing connection", err)
+	timeout := bc.CloseTimeout
+	if timeout <= 0 {
+		timeout = DefaultCloseTimeout
+	}
+	return bc.close(timeout)
+}
+
+// CloseNow closes the UNIX socket connection without waiting for pending commands.
+// These fail with an error wrapping ErrConnectionClosing.
+func (bc *BaseConnection) CloseNow() error {
+	if bc == nil {
+		return nil
+	}
+	return bc.close(0)
+}
+
+// close stops accepting new commands, waits up to timeout for the pending ones and closes the transport
+func (bc *BaseConnection) close(timeout time.Duration) error {
+	bc.mu.Lock()
+	bc.stateMu.Lock()
+	bc.closing = true
+	bc.stateMu.Unlock()
+	pending := bc.lastRequest
+	bc.mu.Unlock()
+
+	if pending != nil && timeout > 0 {
+		select {
+		case <-pending:
+		case <-time.After(timeout):
+			bc.logger().Warnf("<Close> Pending commands did not finish within %s", timeout)
 		}
-		bc.socket = nil
-		return err
 	}
-	return nil
+
+	// Closing the transport must not wait for mu since a command may be stuck in Send
+	bc.stateMu.Lock()
+	transport := bc.live
+	bc.live = nil
+	bc.stateMu.Unlock()
+	if transport == nil {
+		return nil
+	}
+	bc.logger().Debugf("<Close> Closing connection")
+	err := transport.Close()
+	if err != nil {
+		bc.logger().Errorf("<Close> Error closing connection: %v", err)
+	}
+	bc.mu.Lock()
+	if bc.transport == transport {
+		bc.transport = nil
+	}
+	bc.mu.Unlock()
+	return err
+}
+
+// isClosing checks if Close has been called since the connection was established
+func (bc *BaseConnection) isClosing() bool {
+	bc.stateMu.Lock()
+	defer bc.stateMu.Unlock()
+	return bc.closing
 }
 
 // PerformCommand performs an arbitrary command
 func (bc *BaseConnection) PerformCommand(command commands.Command) (commands.Response, error) {
-	err := bc.Send(command)
+	return bc.performCommand(context.Background(), command, nil)
+}
+
+// PerformCommandCtx performs an arbitrary command and stops waiting for its response
+// once ctx is done. In that case the wrapped ctx.Err() is returned and the late response
+// will be discarded when it arrives so the connection remains usable for further commands.
+// Note that cancelling ctx does not cancel the command on the server side.
+func (bc *BaseConnection) PerformCommandCtx(ctx context.Context, command commands.Command) (commands.Response, error) {
+	return bc.performCommand(ctx, command, nil)
+}
+
+// performCommand performs an arbitrary command and decodes the response's result
+// into result unless it is nil
+func (bc *BaseConnection) performCommand(ctx context.Context, command commands.Command, result interface{}) (commands.Response, error) {
+	r, err := bc.sendRequest(command)
 	if err != nil {
 		return nil, err
 	}
-	br, err := bc.ReceiveResponse()
+	return bc.awaitCommand(ctx, r, command, result)
+}
+
+// awaitCommand waits for the response to a command sent via sendRequest and decodes
+// the response's result into result unless it is nil
+func (bc *BaseConnection) awaitCommand(ctx context.Context, r *request, command commands.Command, result interface{}) (resp commands.Response, err error) {
+	if bc.Observer != nil {
+		defer func() { bc.observe(command, r.sent, err) }()
+	}
+	br := &commands.BaseResponse{Result: result}
+	err = bc.awaitResponse(ctx, r, br)
 	if err != nil {
 		return nil, err
 	}
@@ -157,9 +477,86 @@ func (bc *BaseConnection) PerformCommand(command commands.Command) (commands.Res
 	// so the user can work with the received data alongside a simple error object
 
 	if br.GetErrorType() == TaskCanceledException {
-		return br, errors.New(br.GetErrorMessage())
+		return br, &cancelledError{message: br.GetErrorMessage()}
+	}
+	return br, &CommandError{Command: command.GetCommand(), Type: br.GetErrorType(), Reason: br.GetErrorMessage()}
+}
+
+// sendRequest sends a command and queues it for receiving its response
+func (bc *BaseConnection) sendRequest(command commands.Command) (*request, error) {
+	var sent time.Time
+	if bc.Observer != nil {
+		sent = time.Now()
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	err := ErrConnectionClosing
+	if !bc.isClosing() {
+		err = bc.Send(command)
+	}
+	if err != nil {
+		if bc.Observer != nil {
+			bc.observe(command, sent, err)
+		}
+		return nil, err
+	}
+	r := &request{prev: bc.lastRequest, done: make(chan struct{}), transport: bc.transport, sent: sent}
+	bc.lastRequest = r.done
+	bc.updateStats(func(s *connectionStats) { s.pending++ })
+	return r, nil
+}
+
+// observe reports a finished command to the Observer
+func (bc *BaseConnection) observe(command commands.Command, sent time.Time, err error) {
+	bc.Observer.ObserveCommand(command.GetCommand(), time.Since(sent), err)
+}
+
+// awaitResponse waits until it is the given request's turn and receives its response
+// into responseContainer. If ctx is done before that a wrapped ctx.Err() is returned
+// and the response will be discarded in the background.
+// The response is received into a private buffer first so a late response is never
+// written into responseContainer after this returned.
+func (bc *BaseConnection) awaitResponse(ctx context.Context, r *request, responseContainer interface{}) error {
+	if ctx.Done() == nil {
+		return bc.receiveRequest(r, responseContainer)
+	}
+	rc := make(chan error, 1)
+	var raw json.RawMessage
+	go func() {
+		rc <- bc.receiveRequest(r, &raw)
+	}()
+	select {
+	case err := <-rc:
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(raw, responseContainer); err != nil {
+			return &DecodeError{Target: fmt.Sprintf("%T", responseContainer), Err: err}
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Stopped waiting for response: %w", ctx.Err())
 	}
-	return br, fmt.Errorf("InternalServerError: %s, %s, %s", command.GetCommand(), br.GetErrorType(), br.GetErrorMessage())
+}
+
+// receiveRequest blocks until all previous requests have received their
+// responses and then receives the response to the given request
+func (bc *BaseConnection) receiveRequest(r *request, responseContainer interface{}) error {
+	if r.prev != nil {
+		<-r.prev
+	}
+	defer close(r.done)
+	if r.received != nil {
+		defer r.received()
+	}
+	err := bc.receive(r.transport, responseContainer)
+	bc.updateStats(func(s *connectionStats) {
+		s.pending--
+		if err == nil {
+			s.lastResponse = time.Now()
+		}
+	})
+	return err
 }
 
 // ReceiveResponse receives a deserialized response from the server
@@ -184,32 +581,107 @@ func (bc *BaseConnection) receiveServerInitMessage() (*initmessages.ServerInitMe
 
 // Receive a deserialized object
 func (bc *BaseConnection) Receive(responseContainer interface{}) error {
-	if bc.Debug {
-		var b json.RawMessage
-		if err := bc.decoder.Decode(&b); err != nil {
-			if err == io.EOF {
-				return err
-			}
-			return &DecodeError{
-				Err:    err,
-				Target: fmt.Sprintf("%T", responseContainer),
-			}
+	return bc.receive(bc.transport, responseContainer)
+}
+
+// receive a deserialized object from the given transport.
+// Unless the raw message is needed for Tap or Debug it is decoded directly from the socket.
+func (bc *BaseConnection) receive(transport ConnectionTransport, responseContainer interface{}) error {
+	if transport == nil {
+		if bc.initMessage == nil {
+			return ErrNotConnected
 		}
-		log.Println("[DEBUG] <Recv>", string(b))
-		return json.Unmarshal(b, responseContainer)
+		return &TransportError{Err: io.EOF}
 	}
-	if err := bc.decoder.Decode(responseContainer); err != nil {
-		if err == io.EOF {
+	if dt, ok := transport.(deadlineTransport); ok && bc.ReadTimeout > 0 {
+		if err := dt.SetReadDeadline(time.Now().Add(bc.ReadTimeout)); err != nil {
 			return err
 		}
-		return &DecodeError{
-			Err:    err,
-			Target: fmt.Sprintf("%T", responseContainer),
+	}
+	if vt, ok := transport.(valueTransport); ok && bc.Tap == nil && !bc.Debug {
+		if err := vt.ReceiveValue(responseContainer); err != nil {
+			return bc.decodeError(transport, err, responseContainer)
 		}
+		return nil
+	}
+	b, err := transport.Receive()
+	if err != nil {
+		return bc.decodeError(transport, err, responseContainer)
+	}
+	bc.tap("< ", b)
+	if bc.Debug {
+		bc.logger().Debugf("<Recv> %s", b)
+	}
+	if err = json.Unmarshal(b, responseContainer); err != nil {
+		return bc.decodeError(transport, err, responseContainer)
 	}
 	return nil
 }
 
+// decodeError wraps an error returned by the transport as TransportError
+// and any other error that occurred while unmarshalling as DecodeError
+func (bc *BaseConnection) decodeError(transport ConnectionTransport, err error, responseContainer interface{}) error {
+	if isTransportError(err) {
+		if bc.isClosing() {
+			return fmt.Errorf("%w: %v", ErrConnectionClosing, err)
+		}
+		err = transportError(err)
+		bc.notifyDisconnect(transport, err)
+		if bc.onTransportError != nil {
+			return bc.onTransportError(transport, err)
+		}
+		return err
+	}
+	return &DecodeError{
+		Err:    err,
+		Target: fmt.Sprintf("%T", responseContainer),
+	}
+}
+
+// resetState marks the given transport as established
+func (bc *BaseConnection) resetState(transport ConnectionTransport) {
+	bc.stateMu.Lock()
+	defer bc.stateMu.Unlock()
+	bc.live = transport
+	bc.closing = false
+	bc.disconnected = false
+}
+
+// isLive checks if the given transport is the currently established one
+func (bc *BaseConnection) isLive(transport ConnectionTransport) bool {
+	bc.stateMu.Lock()
+	defer bc.stateMu.Unlock()
+	return transport == bc.live
+}
+
+// notifyDisconnect calls OnDisconnect once for a fatal error of the current transport
+// unless the connection is being closed
+func (bc *BaseConnection) notifyDisconnect(transport ConnectionTransport, err error) {
+	bc.stateMu.Lock()
+	fire := transport == bc.live && !bc.closing && !bc.disconnected
+	if fire {
+		bc.disconnected = true
+	}
+	bc.stateMu.Unlock()
+	if !fire {
+		return
+	}
+	bc.logger().Warnf("<Disconnect> Connection to control server lost: %v", err)
+	if bc.OnDisconnect != nil {
+		bc.OnDisconnect(err)
+	}
+}
+
+// isTransportError checks if err was caused by a broken or closed socket
+func isTransportError(err error) bool {
+	if errors.Is(err, ErrTransport) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var oe *net.OpError
+	return errors.As(err, &oe)
+}
+
 // ReceiveJson returns a server response as a JSON []byteg
 func (bc *BaseConnection) ReceiveJson() ([]byte, error) {
 	var raw json.RawMessage
@@ -229,15 +701,71 @@ func (bc *BaseConnection) ReceiveJSONString() (string, error) {
 	return string(b), nil
 }
 
-// Send arbitrary data
+// Send arbitrary data. Commands and init messages implementing commands.Validator are
+// validated first so invalid settings such as unknown code channels fail before anything is sent.
+// Messages exceeding MaxMessageSize are rejected as well.
 func (bc *BaseConnection) Send(data interface{}) error {
-	b, err := json.Marshal(data)
+	if v, ok := data.(commands.Validator); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	buf, err := bc.encode(data)
 	if err != nil {
 		return err
 	}
+	defer releaseBuffer(buf)
+	b := buf.Bytes()
+	if max := bc.maxMessageSize(); max > 0 && len(b) > max {
+		return fmt.Errorf("%w: %d bytes exceed the limit of %d bytes", ErrMessageTooLarge, len(b), max)
+	}
+	bc.tap("> ", b)
 	if bc.Debug {
-		log.Println("[DEBUG] <Send>", string(b))
+		bc.logger().Debugf("<Send> %s", b)
+	}
+	transport := bc.transport
+	if transport == nil {
+		if bc.initMessage == nil {
+			return ErrNotConnected
+		}
+		return &TransportError{Err: io.ErrClosedPipe}
+	}
+	if dt, ok := transport.(deadlineTransport); ok && bc.WriteTimeout > 0 {
+		if err = dt.SetWriteDeadline(time.Now().Add(bc.WriteTimeout)); err != nil {
+			return err
+		}
+	}
+	err = transport.Send(b)
+	if err != nil && isTransportError(err) {
+		err = transportError(err)
+		bc.notifyDisconnect(transport, err)
+		if bc.onTransportError != nil {
+			return bc.onTransportError(transport, err)
+		}
 	}
-	_, err = bc.socket.Write(b)
 	return err
 }
+
+// maxMessageSize returns the effective limit of the message size or a non-positive value if there is none
+func (bc *BaseConnection) maxMessageSize() int {
+	if bc.MaxMessageSize == 0 {
+		return DefaultMaxMessageSize
+	}
+	return bc.MaxMessageSize
+}
+
+// tap writes a raw message along with its direction marker to Tap if set
+func (bc *BaseConnection) tap(direction string, b []byte) {
+	if bc.Tap == nil {
+		return
+	}
+	bc.tapMu.Lock()
+	defer bc.tapMu.Unlock()
+	line := make([]byte, 0, len(direction)+len(b)+1)
+	line = append(line, direction...)
+	line = append(line, b...)
+	line = append(line, '\n')
+	if _, err := bc.Tap.Write(line); err != nil {
+		bc.logger().Debugf("<Tap> Failed to write message: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package connection
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/boards"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/state"
+)
+
+// ErrNoBoard is returned by GetBoardInfo if no board is connected
+var ErrNoBoard = errors.New("No board connected")
+
+// BoardInfo holds the static details of the main board and the control server
+type BoardInfo struct {
+	// Name is the full name of the board, e.g. Duet 3 MB6HC
+	Name string
+	// ShortName is the short code of the board, e.g. MB6HC
+	ShortName string
+	// FirmwareName is the name of the firmware, e.g. RepRapFirmware
+	FirmwareName string
+	// FirmwareVersion is the version of the firmware
+	FirmwareVersion string
+	// FirmwareDate is the date of the firmware build
+	FirmwareDate string
+	// UniqueId of the board
+	UniqueId string
+	// DsfVersion is the version of Duet Software Framework
+	DsfVersion string
+}
+
+// GetBoardInfo returns the details of the main board (boards[0]) and the DSF version.
+// The result is cached after the first successful call since these values only change on
+// firmware updates which restart the control server and thereby this connection.
+func (bcc *BaseCommandConnection) GetBoardInfo() (*BoardInfo, error) {
+	return bcc.GetBoardInfoCtx(context.Background())
+}
+
+// GetBoardInfoCtx returns the details of the main board like GetBoardInfo or stops waiting once ctx is done
+func (bcc *BaseCommandConnection) GetBoardInfoCtx(ctx context.Context) (*BoardInfo, error) {
+	bcc.boardInfoMu.Lock()
+	defer bcc.boardInfoMu.Unlock()
+	if bcc.boardInfo != nil {
+		info := *bcc.boardInfo
+		return &info, nil
+	}
+
+	var bs []boards.Board
+	if err := bcc.GetObjectModelKeyCtx(ctx, "boards", &bs); err != nil {
+		return nil, err
+	}
+	if len(bs) == 0 {
+		return nil, ErrNoBoard
+	}
+	var st state.State
+	if err := bcc.GetObjectModelKeyCtx(ctx, "state", &st); err != nil {
+		return nil, err
+	}
+
+	b := bs[0]
+	bcc.boardInfo = &BoardInfo{
+		Name:            b.Name,
+		ShortName:       b.ShortName,
+		FirmwareName:    b.FirmwareName,
+		FirmwareVersion: b.FirmwareVersion,
+		FirmwareDate:    b.FirmwareDate,
+		UniqueId:        b.UniqueId,
+		DsfVersion:      st.DsfVersion,
+	}
+	info := *bcc.boardInfo
+	return &info, nil
+}
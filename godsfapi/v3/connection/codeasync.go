@@ -0,0 +1,43 @@
+package connection
+
+import (
+	"context"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+)
+
+// AsyncCodeResult is delivered once a code sent via PerformCodeAsync has finished
+type AsyncCodeResult struct {
+	// Result of the code. This may be nil even without an error, e.g. for Asynchronous codes
+	Result *commands.CodeResult
+	// Err is set if the code could not be executed
+	Err error
+}
+
+// PerformCodeAsync sends a code and returns immediately. The returned channel receives exactly one
+// AsyncCodeResult once the code has finished and is closed afterwards. The channel is buffered so
+// it is not required to read from it.
+//
+// Multiple codes may be in flight at the same time, also alongside other commands on this
+// connection. Their responses are matched in the order they were sent. Note that the control
+// server processes the commands of a connection one after another, so commands sent after a
+// long-running code (e.g. G29) will only finish after it. Use a separate connection to run
+// other commands in parallel.
+func (bcc *BaseCommandConnection) PerformCodeAsync(code *commands.Code) (<-chan AsyncCodeResult, error) {
+	r, err := bcc.sendCode(code)
+	if err != nil {
+		return nil, err
+	}
+	rc := make(chan AsyncCodeResult, 1)
+	go func() {
+		defer close(rc)
+		var cr commands.CodeResult
+		_, err := bcc.awaitCommand(context.Background(), r, code, &cr)
+		if err != nil {
+			rc <- AsyncCodeResult{Err: err}
+			return
+		}
+		rc <- AsyncCodeResult{Result: codeResult(cr)}
+	}()
+	return rc, nil
+}
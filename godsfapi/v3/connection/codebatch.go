@@ -0,0 +1,122 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+)
+
+// BatchError is returned if at least one code of a batch failed
+type BatchError struct {
+	// Codes that were requested
+	Codes []*commands.Code
+	// Errors holds the error of the code at the same index or nil if it succeeded or was not executed
+	Errors []error
+}
+
+// Unwrap returns the first error of the batch
+func (e *BatchError) Unwrap() error {
+	for _, err := range e.Errors {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *BatchError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	failed := 0
+	first := -1
+	for i, err := range e.Errors {
+		if err != nil {
+			failed++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return "No codes failed"
+	}
+	return fmt.Sprintf("%d of %d codes failed, first %s: %v", failed, len(e.Errors), e.Codes[first].ShortString(), e.Errors[first])
+}
+
+// PerformCodes executes the given codes in order and returns their results at the same indices.
+// Execution stops at the first code that fails and a *BatchError is returned holding the error
+// at the index of the failed code. Results of codes that were not executed remain nil.
+// Since the remainder has to be held back until the previous code finished, codes are sent
+// one by one. Use PerformCodesContinueOnError to pipeline them instead.
+func (bcc *BaseCommandConnection) PerformCodes(ctx context.Context, codes []*commands.Code) ([]*commands.CodeResult, error) {
+	results := make([]*commands.CodeResult, len(codes))
+	for i, c := range codes {
+		cr, err := bcc.PerformCodeCtx(ctx, c)
+		if err != nil {
+			errs := make([]error, len(codes))
+			errs[i] = err
+			return results, &BatchError{Codes: codes, Errors: errs}
+		}
+		results[i] = cr
+	}
+	return results, nil
+}
+
+// PerformCodesContinueOnError sends all codes at once without waiting for previous ones to finish
+// and collects their results in order at the same indices. Failing codes do not stop the remaining
+// ones. If at least one code failed a *BatchError is returned holding the individual errors.
+func (bcc *BaseCommandConnection) PerformCodesContinueOnError(ctx context.Context, codes []*commands.Code) ([]*commands.CodeResult, error) {
+	results := make([]*commands.CodeResult, len(codes))
+	errs := make([]error, len(codes))
+	failed := false
+
+	// Receive responses concurrently so the control server is never blocked writing them
+	type pending struct {
+		index int
+		r     *request
+	}
+	pc := make(chan pending, len(codes))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range pc {
+			var cr commands.CodeResult
+			_, err := bcc.awaitCommand(ctx, p.r, codes[p.index], &cr)
+			if err != nil {
+				errs[p.index] = err
+				continue
+			}
+			results[p.index] = codeResult(cr)
+		}
+	}()
+
+	var sendErr error
+	for i, c := range codes {
+		if sendErr != nil {
+			errs[i] = sendErr
+			continue
+		}
+		r, err := bcc.sendCode(c)
+		if err != nil {
+			sendErr = err
+			errs[i] = err
+			continue
+		}
+		pc <- pending{index: i, r: r}
+	}
+	close(pc)
+	<-done
+
+	for _, err := range errs {
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+	if failed {
+		return results, &BatchError{Codes: codes, Errors: errs}
+	}
+	return results, nil
+}
@@ -0,0 +1,87 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// CodePool executes codes submitted from any number of goroutines over one or more command connections.
+//
+// All codes of a code channel are sent over the same connection, so they are executed in the order
+// they were submitted. Channels are distributed over the connections so codes of different channels
+// can run in parallel as long as there are enough connections; with a single connection all codes
+// are executed one after another.
+type CodePool struct {
+	conns []*CommandConnection
+	// mu guards channels and next
+	mu       sync.Mutex
+	channels map[types.CodeChannel]*CommandConnection
+	next     int
+}
+
+// NewCodePool creates a new pool using the given established connections
+func NewCodePool(conns ...*CommandConnection) (*CodePool, error) {
+	if len(conns) == 0 {
+		return nil, errors.New("Code pool requires at least one connection")
+	}
+	return &CodePool{
+		conns:    conns,
+		channels: make(map[types.CodeChannel]*CommandConnection),
+	}, nil
+}
+
+// ConnectCodePool establishes size command connections to the given UNIX socket file
+// and creates a new pool using them
+func ConnectCodePool(size int, socketPath string) (*CodePool, error) {
+	conns := make([]*CommandConnection, 0, size)
+	for i := 0; i < size; i++ {
+		cc := &CommandConnection{}
+		if err := cc.Connect(socketPath); err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return nil, err
+		}
+		conns = append(conns, cc)
+	}
+	return NewCodePool(conns...)
+}
+
+// Submit executes a code and waits for its result. It is safe to call from multiple goroutines.
+func (p *CodePool) Submit(code *commands.Code) (*commands.CodeResult, error) {
+	return p.SubmitCtx(context.Background(), code)
+}
+
+// SubmitCtx executes a code and waits for its result or stops waiting once ctx is done.
+// The code itself will still be executed by the control server in that case.
+func (p *CodePool) SubmitCtx(ctx context.Context, code *commands.Code) (*commands.CodeResult, error) {
+	return p.connection(code.Channel).PerformCodeCtx(ctx, code)
+}
+
+// connection returns the connection that is used for the given channel
+func (p *CodePool) connection(channel types.CodeChannel) *CommandConnection {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cc, ok := p.channels[channel]
+	if !ok {
+		cc = p.conns[p.next%len(p.conns)]
+		p.next++
+		p.channels[channel] = cc
+	}
+	return cc
+}
+
+// Close all connections of the pool
+func (p *CodePool) Close() error {
+	var err error
+	for _, cc := range p.conns {
+		if cerr := cc.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
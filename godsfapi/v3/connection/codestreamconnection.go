@@ -0,0 +1,225 @@
+package connection
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// DefaultCodeStreamDepth is the number of codes a CodeStreamConnection buffers if Depth is not set
+const DefaultCodeStreamDepth = 64
+
+var (
+	// ErrCodeStreamFull is returned by TryWrite if the buffer of the code stream is full
+	ErrCodeStreamFull = errors.New("Code stream buffer is full")
+	// ErrCodeStreamClosed is returned when writing to a code stream that has been closed
+	ErrCodeStreamClosed = errors.New("Code stream is closed")
+)
+
+// CodeStreamConnection sends codes as a continuous stream of text lines and receives
+// the code replies the same way. Codes are buffered and sent in the background. Since the
+// control server stops reading once it executes BufferSize codes, a full buffer means the
+// machine is busy and Write blocks until there is room again.
+//
+// Write, TryWrite, QueueDepth and Flush may be used from multiple goroutines while another one
+// reads the replies. Other functions of the connection must not be used once connected.
+type CodeStreamConnection struct {
+	BaseConnection
+	// Depth is the number of codes that are buffered before Write blocks.
+	// It is applied on Connect and defaults to DefaultCodeStreamDepth.
+	Depth int
+
+	conn   net.Conn
+	reader *bufio.Reader
+	queue  chan string
+	stop   chan struct{}
+	// mu guards the following fields
+	mu sync.Mutex
+	// drained is signalled whenever a code has been sent or the stream failed
+	drained sync.Cond
+	pending int
+	err     error
+	closed  bool
+}
+
+// Connect establishes a code stream to the given UNIX socket file. bufferSize is the number of codes
+// the control server executes simultaneously (see initmessages.DefaultCodeStreamBufferSize).
+func (csc *CodeStreamConnection) Connect(bufferSize int, channel types.CodeChannel, socketPath string) error {
+	err := csc.BaseConnection.Connect(initmessages.NewCodeStreamInitMessage(bufferSize, channel), socketPath)
+	if err != nil {
+		return err
+	}
+	nt, ok := csc.transport.(*netTransport)
+	if !ok {
+		csc.BaseConnection.Close()
+		return errors.New("Code streams require a socket connection")
+	}
+
+	depth := csc.Depth
+	if depth <= 0 {
+		depth = DefaultCodeStreamDepth
+	}
+	csc.mu.Lock()
+	csc.conn = nt.conn
+	// The decoder may have read ahead past the response to the init message
+	buffered, _ := ioutil.ReadAll(nt.decoder.Buffered())
+	buffered = bytes.TrimLeft(buffered, "\r\n")
+	csc.reader = bufio.NewReader(io.MultiReader(bytes.NewReader(buffered), nt.conn))
+	csc.queue = make(chan string, depth)
+	csc.stop = make(chan struct{})
+	csc.drained.L = &csc.mu
+	csc.pending = 0
+	csc.err = nil
+	csc.closed = false
+	go csc.send(csc.conn, csc.queue, csc.stop)
+	csc.mu.Unlock()
+	return nil
+}
+
+// send writes queued codes to the socket until stop is closed
+func (csc *CodeStreamConnection) send(conn net.Conn, queue <-chan string, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case code := <-queue:
+			if csc.WriteTimeout > 0 {
+				conn.SetWriteDeadline(time.Now().Add(csc.WriteTimeout))
+			}
+			_, err := io.WriteString(conn, code+"\n")
+
+			csc.mu.Lock()
+			csc.pending--
+			if err != nil && csc.err == nil {
+				csc.err = err
+			}
+			csc.drained.Broadcast()
+			csc.mu.Unlock()
+		}
+	}
+}
+
+// Write queues a code for sending. It blocks while the buffer is full.
+// An error is returned if the stream was closed or a previous code could not be sent.
+func (csc *CodeStreamConnection) Write(code string) error {
+	return csc.write(code, true)
+}
+
+// TryWrite queues a code for sending like Write but returns ErrCodeStreamFull instead of blocking
+func (csc *CodeStreamConnection) TryWrite(code string) error {
+	return csc.write(code, false)
+}
+
+// write queues a code and optionally waits for room in the buffer
+func (csc *CodeStreamConnection) write(code string, block bool) error {
+	if strings.ContainsAny(code, "\r\n") {
+		return errors.New("Codes of a code stream must not contain line breaks")
+	}
+	csc.mu.Lock()
+	if csc.closed || csc.queue == nil {
+		csc.mu.Unlock()
+		return ErrCodeStreamClosed
+	}
+	if csc.err != nil {
+		err := csc.err
+		csc.mu.Unlock()
+		return err
+	}
+	queue, stop := csc.queue, csc.stop
+	csc.pending++
+	csc.mu.Unlock()
+
+	if block {
+		select {
+		case queue <- code:
+			return nil
+		case <-stop:
+			csc.unqueue()
+			return ErrCodeStreamClosed
+		}
+	}
+	select {
+	case queue <- code:
+		return nil
+	default:
+		csc.unqueue()
+		return ErrCodeStreamFull
+	}
+}
+
+// unqueue reverts the accounting of a code that could not be queued
+func (csc *CodeStreamConnection) unqueue() {
+	csc.mu.Lock()
+	csc.pending--
+	csc.drained.Broadcast()
+	csc.mu.Unlock()
+}
+
+// QueueDepth returns the number of codes that have been written but not sent yet
+func (csc *CodeStreamConnection) QueueDepth() int {
+	csc.mu.Lock()
+	defer csc.mu.Unlock()
+	return csc.pending
+}
+
+// Flush waits until all queued codes have been sent to the control server.
+// This does not wait for the codes to be executed.
+func (csc *CodeStreamConnection) Flush() error {
+	csc.mu.Lock()
+	defer csc.mu.Unlock()
+	for csc.pending > 0 && csc.err == nil && !csc.closed {
+		csc.drained.Wait()
+	}
+	if csc.err != nil {
+		return csc.err
+	}
+	if csc.pending > 0 {
+		return ErrCodeStreamClosed
+	}
+	return nil
+}
+
+// ReadReply waits for the next code reply. Replies may span multiple lines
+// which are returned one at a time.
+func (csc *CodeStreamConnection) ReadReply() (string, error) {
+	csc.mu.Lock()
+	reader, conn := csc.reader, csc.conn
+	csc.mu.Unlock()
+	if reader == nil {
+		return "", io.EOF
+	}
+	if csc.ReadTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(csc.ReadTimeout))
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Close the code stream. Codes that have not been sent yet are discarded; use Flush first to avoid that.
+func (csc *CodeStreamConnection) Close() error {
+	if csc == nil {
+		return nil
+	}
+	csc.mu.Lock()
+	if !csc.closed && csc.stop != nil {
+		close(csc.stop)
+	}
+	csc.closed = true
+	if csc.drained.L != nil {
+		csc.drained.Broadcast()
+	}
+	csc.mu.Unlock()
+	return csc.BaseConnection.Close()
+}
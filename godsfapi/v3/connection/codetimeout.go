@@ -0,0 +1,103 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// ErrCodeTimeout is matched by errors.Is for every CodeTimeoutError
+var ErrCodeTimeout = errors.New("Code did not complete in time")
+
+// ErrNoSeparateConnection is returned when a code channel cannot be cancelled because this
+// connection was not established via Connect and no second connection can be opened
+var ErrNoSeparateConnection = errors.New("Connection was not established via Connect")
+
+// CodeTimeoutError is returned by PerformCodeTimeout if a code did not complete in time
+type CodeTimeoutError struct {
+	// Code that timed out
+	Code string
+	// Timeout that elapsed
+	Timeout time.Duration
+	// CancelErr is set if the code could not be cancelled on the control server
+	CancelErr error
+}
+
+func (e *CodeTimeoutError) Is(target error) bool { return target == ErrCodeTimeout }
+
+func (e *CodeTimeoutError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	if e.CancelErr != nil {
+		return fmt.Sprintf("Code %s did not complete within %s and could not be cancelled: %v", e.Code, e.Timeout, e.CancelErr)
+	}
+	return fmt.Sprintf("Code %s did not complete within %s", e.Code, e.Timeout)
+}
+
+// PerformCodeTimeout executes a code and waits at most timeout for its result. Once the timeout
+// elapses the pending codes of the code's channel are cancelled using InvalidateChannel, which
+// includes the code itself and all other codes waiting on that channel, and a *CodeTimeoutError
+// is returned. The connection remains usable since the late response is discarded.
+//
+// The control server only processes one command per connection at a time, so the cancellation is
+// sent over a short-lived second connection. This requires the connection to be established via
+// Connect; otherwise the code is only abandoned and CancelErr of the returned error is set.
+func (bcc *BaseCommandConnection) PerformCodeTimeout(code *commands.Code, timeout time.Duration) (*commands.CodeResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cr, err := bcc.PerformCodeCtx(ctx, code)
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) {
+		return cr, err
+	}
+	cancelErr := bcc.invalidateChannelSeparately(code.Channel)
+	return nil, &CodeTimeoutError{Code: code.String(), Timeout: timeout, CancelErr: cancelErr}
+}
+
+// CancelPendingCode cancels a code that was sent via PerformCodeAsync or is being executed by another
+// goroutine. The waiting caller receives an error matching ErrCodeCancelled.
+//
+// DSF has no way to cancel a single code, so this invalidates the code's channel. That cancels all
+// other pending codes on the same channel as well. The cancellation uses a short-lived second
+// connection like PerformCodeTimeout does, because commands on this connection are processed in
+// order. Codes that RepRapFirmware has already started and cannot interrupt finish regardless. This
+// applies to moves in the motion queue and codes like M303. Only waiting for them is cancelled.
+// Connections established via ConnectTransport cannot open a second connection and return
+// ErrNoSeparateConnection since an in-band cancellation would only be processed after the code itself.
+func (bcc *BaseCommandConnection) CancelPendingCode(code *commands.Code) error {
+	return bcc.invalidateChannelSeparately(code.Channel)
+}
+
+// invalidateChannelSeparately cancels the codes of the given channel using a new connection.
+// ErrNoSeparateConnection is returned if this connection was not established via Connect
+// unless invalidateSeparately is set.
+func (bcc *BaseCommandConnection) invalidateChannelSeparately(channel types.CodeChannel) error {
+	if channel == "" {
+		channel = types.SBC
+	}
+	if bcc.network == "" {
+		if bcc.invalidateSeparately != nil {
+			return bcc.invalidateSeparately(channel)
+		}
+		return ErrNoSeparateConnection
+	}
+	c := &BaseCommandConnection{
+		BaseConnection: BaseConnection{
+			Logger:       bcc.Logger,
+			Dialer:       bcc.Dialer,
+			DialTimeout:  bcc.DialTimeout,
+			ReadTimeout:  bcc.ReadTimeout,
+			WriteTimeout: bcc.WriteTimeout,
+		},
+	}
+	if err := c.connect(initmessages.NewCommandInitMessage(), bcc.network, bcc.address); err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.InvalidateChannel(channel)
+}
@@ -1,15 +1,172 @@
 package connection
 
 import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine"
 )
 
+// keepalivePath is resolved to check if the control server is still responding
+// since this is one of the cheapest commands available
+const keepalivePath = "0:/"
+
 // CommandConnection used to send commands to the control server
 type CommandConnection struct {
 	BaseCommandConnection
+	// KeepaliveInterval is the interval in which the connection is checked for liveness
+	// while it is connected. Zero disables the keepalive.
+	KeepaliveInterval time.Duration
+	// OnKeepaliveError is called if the control server did not respond to a keepalive check
+	OnKeepaliveError func(err error)
+	// PrefetchModel makes Connect fetch the full object model so it is available via CachedModel
+	// right away. Connect fails if the model cannot be fetched.
+	PrefetchModel bool
+
+	// keepaliveMu guards stopKeepalive
+	keepaliveMu   sync.Mutex
+	stopKeepalive chan struct{}
+	// modelMu guards model
+	modelMu sync.Mutex
+	model   *machine.SafeModel
 }
 
 // Connect sends a CommandInitMessage to the server
 func (cc *CommandConnection) Connect(socketPath string) error {
-	return cc.BaseConnection.Connect(initmessages.NewCommandInitMessage(), socketPath)
+	err := cc.BaseConnection.Connect(initmessages.NewCommandInitMessage(), socketPath)
+	if err != nil {
+		return err
+	}
+	if cc.PrefetchModel {
+		if err = cc.RefreshModel(); err != nil {
+			cc.CloseNow()
+			return err
+		}
+	}
+	if cc.KeepaliveInterval > 0 {
+		cc.startKeepalive()
+	}
+	return nil
+}
+
+// CachedModel returns the object model cached by PrefetchModel, RefreshModel, AttachModelWatcher
+// or AttachSharedModel or nil if nothing has been cached yet.
+//
+// Unless a ModelWatcher is attached the cached model is a snapshot that is never updated by
+// itself and becomes stale as soon as the machine changes. This is fine for rarely changing
+// data such as the configuration of axes or tools but live values have to be refreshed or
+// queried directly.
+func (cc *CommandConnection) CachedModel() *machine.SafeModel {
+	cc.modelMu.Lock()
+	defer cc.modelMu.Unlock()
+	return cc.model
+}
+
+// RefreshModel fetches the full object model and replaces the cached one with it.
+// If a ModelWatcher is attached its model is replaced until the next update arrives.
+func (cc *CommandConnection) RefreshModel() error {
+	mm, err := cc.GetObjectModel()
+	if err != nil {
+		return err
+	}
+	cc.modelMu.Lock()
+	defer cc.modelMu.Unlock()
+	if cc.model == nil {
+		cc.model = machine.NewSafeModel(mm)
+	} else {
+		cc.model.Set(mm)
+	}
+	return nil
+}
+
+// AttachModelWatcher makes CachedModel return the model of the given watcher which is kept
+// up-to-date by its subscription for as long as the watcher is running
+func (cc *CommandConnection) AttachModelWatcher(w *ModelWatcher) {
+	cc.modelMu.Lock()
+	defer cc.modelMu.Unlock()
+	cc.model = w.Model()
+}
+
+// AttachSharedModel makes CachedModel return the given shared model and writes the full object model
+// fetched from this connection into it for the initial synchronization. Afterwards it is typically
+// kept up-to-date by a watcher created via NewSharedModelWatcher and RefreshModel writes into it.
+func (cc *CommandConnection) AttachSharedModel(sm *machine.SharedModel) error {
+	mm, err := cc.GetObjectModel()
+	if err != nil {
+		return err
+	}
+	sm.Set(mm)
+	cc.modelMu.Lock()
+	defer cc.modelMu.Unlock()
+	cc.model = &sm.SafeModel
+	return nil
+}
+
+// Close the UNIX socket connection and stop the keepalive
+func (cc *CommandConnection) Close() error {
+	if cc == nil {
+		return nil
+	}
+	cc.stopKeepaliveLoop()
+	return cc.BaseCommandConnection.Close()
+}
+
+// CloseNow closes the UNIX socket connection without waiting for pending commands and stops the keepalive
+func (cc *CommandConnection) CloseNow() error {
+	if cc == nil {
+		return nil
+	}
+	cc.stopKeepaliveLoop()
+	return cc.BaseCommandConnection.CloseNow()
+}
+
+// stopKeepaliveLoop stops the keepalive if it is running
+func (cc *CommandConnection) stopKeepaliveLoop() {
+	cc.keepaliveMu.Lock()
+	defer cc.keepaliveMu.Unlock()
+	if cc.stopKeepalive != nil {
+		close(cc.stopKeepalive)
+		cc.stopKeepalive = nil
+	}
+}
+
+// startKeepalive periodically resolves a path to check if the control server still responds.
+// A keepalive that is still running is stopped first.
+func (cc *CommandConnection) startKeepalive() {
+	stop := make(chan struct{})
+	cc.keepaliveMu.Lock()
+	if cc.stopKeepalive != nil {
+		close(cc.stopKeepalive)
+	}
+	cc.stopKeepalive = stop
+	cc.keepaliveMu.Unlock()
+	interval := cc.KeepaliveInterval
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				// Bypass the path cache so the request always reaches the control server
+				var resolved string
+				_, err := cc.performCommand(context.Background(), commands.NewResolvePath(keepalivePath), &resolved)
+				if err == nil {
+					continue
+				}
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if cc.OnKeepaliveError != nil {
+					cc.OnKeepaliveError(err)
+				}
+			}
+		}
+	}()
 }
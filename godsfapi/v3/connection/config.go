@@ -0,0 +1,76 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/directories"
+)
+
+const (
+	// configFile is the name of the main configuration file in the system directory
+	configFile = "config.g"
+	// configBackupFile is the name of the backup the control server falls back to if config.g is missing
+	configBackupFile = "config.g.bak"
+)
+
+// ReadConfig reads the content of config.g from the configured system directory.
+// Like DownloadFile this requires the client to run on the SBC.
+func (bcc *BaseCommandConnection) ReadConfig() (string, error) {
+	sys, err := bcc.systemDirectory()
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err = bcc.DownloadFile(sys+configFile, &b, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// WriteConfig replaces config.g in the configured system directory with the given content.
+// The previous config.g is preserved as config.g.bak which the control server also uses
+// if config.g is missing. Both files are replaced atomically as described by UploadFile.
+// If reload is set, the new configuration is run using M98 once it has been written which
+// fails like RunMacro if it produces an error.
+func (bcc *BaseCommandConnection) WriteConfig(content string, reload bool) error {
+	sys, err := bcc.systemDirectory()
+	if err != nil {
+		return err
+	}
+	path := sys + configFile
+	var old bytes.Buffer
+	err = bcc.DownloadFile(path, &old, nil)
+	if err == nil {
+		err = bcc.UploadFile(sys+configBackupFile, &old, nil)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = bcc.UploadFile(path, strings.NewReader(content), nil); err != nil {
+		return err
+	}
+	if reload {
+		return bcc.RunMacro(context.Background(), path)
+	}
+	return nil
+}
+
+// systemDirectory returns the RepRapFirmware-style path of the configured system directory
+// including a trailing slash
+func (bcc *BaseCommandConnection) systemDirectory() (string, error) {
+	var dirs directories.Directories
+	if err := bcc.GetObjectModelKey("directories", &dirs); err != nil {
+		return "", err
+	}
+	sys := dirs.System
+	if sys == "" {
+		sys = directories.DefaultSystemPath
+	}
+	return strings.TrimSuffix(sys, "/") + "/", nil
+}
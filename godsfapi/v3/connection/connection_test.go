@@ -0,0 +1,307 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/mock"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// connectMock establishes a command connection over a new mock transport
+func connectMock(t *testing.T) (*CommandConnection, *mock.Transport) {
+	t.Helper()
+	mt := mock.NewTransport()
+	if err := mt.EnqueueHandshake(1); err != nil {
+		t.Fatal(err)
+	}
+	cc := &CommandConnection{}
+	if err := cc.ConnectTransport(initmessages.NewCommandInitMessage(), mt); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.CloseNow() })
+	return cc, mt
+}
+
+// testCode returns a valid code to send
+func testCode(t *testing.T, code string) *commands.Code {
+	t.Helper()
+	c, err := commands.ParseCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Channel = types.SBC
+	return c
+}
+
+// codeReply returns a code result consisting of a single success message
+func codeReply(content string) commands.CodeResult {
+	return commands.CodeResult{{Type: messages.Success, Content: content}}
+}
+
+func TestHandshake(t *testing.T) {
+	mt := mock.NewTransport()
+	if err := mt.EnqueueHandshake(42); err != nil {
+		t.Fatal(err)
+	}
+	cc := &CommandConnection{}
+	if err := cc.ConnectTransport(initmessages.NewCommandInitMessage(), mt); err != nil {
+		t.Fatal(err)
+	}
+	defer cc.CloseNow()
+
+	if id := cc.ConnectionId(); id != 42 {
+		t.Errorf("ConnectionId() = %d, want 42", id)
+	}
+	if v := cc.ServerVersion(); v != initmessages.ProtocolVersion {
+		t.Errorf("ServerVersion() = %d, want %d", v, initmessages.ProtocolVersion)
+	}
+	var im initmessages.BaseInitMessage
+	if err := mt.LastSent(&im); err != nil {
+		t.Fatal(err)
+	}
+	if im.Mode != initmessages.ConnectionModeCommand {
+		t.Errorf("init message mode = %s, want %s", im.Mode, initmessages.ConnectionModeCommand)
+	}
+	if err := cc.ConnectTransport(initmessages.NewCommandInitMessage(), mock.NewTransport()); !errors.Is(err, ErrAlreadyConnected) {
+		t.Errorf("second ConnectTransport() = %v, want ErrAlreadyConnected", err)
+	}
+}
+
+func TestHandshakeIncompatible(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(mt *mock.Transport) error
+	}{
+		{
+			name: "OlderServer",
+			respond: func(mt *mock.Transport) error {
+				return mt.Enqueue(initmessages.ServerInitMessage{Version: initmessages.ProtocolVersion - 1, Id: 1})
+			},
+		},
+		{
+			name: "RejectedByServer",
+			respond: func(mt *mock.Transport) error {
+				if err := mt.Enqueue(initmessages.ServerInitMessage{Version: initmessages.ProtocolVersion + 1, Id: 1}); err != nil {
+					return err
+				}
+				return mt.EnqueueError(IncompatibleVersionException, "Incompatible protocol version")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := mock.NewTransport()
+			if err := tt.respond(mt); err != nil {
+				t.Fatal(err)
+			}
+			cc := &CommandConnection{}
+			err := cc.ConnectTransport(initmessages.NewCommandInitMessage(), mt)
+			if !errors.Is(err, ErrIncompatibleVersion) {
+				t.Fatalf("ConnectTransport() = %v, want ErrIncompatibleVersion", err)
+			}
+			if !mt.IsClosed() {
+				t.Error("transport was not closed after a failed handshake")
+			}
+		})
+	}
+}
+
+func TestPipelinedOrdering(t *testing.T) {
+	cc, mt := connectMock(t)
+
+	var pending []<-chan AsyncCodeResult
+	for _, code := range []string{"M115", "M114", "M122"} {
+		rc, err := cc.PerformCodeAsync(testCode(t, code))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pending = append(pending, rc)
+	}
+	replies := []string{"firmware", "position", "diagnostics"}
+	for _, r := range replies {
+		if err := mt.EnqueueResult(codeReply(r)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, rc := range pending {
+		r := <-rc
+		if r.Err != nil {
+			t.Fatalf("code %d failed: %v", i, r.Err)
+		}
+		if r.Result == nil || r.Result.FullText() != replies[i] {
+			t.Errorf("code %d got %v, want %s", i, r.Result, replies[i])
+		}
+	}
+	sent, err := mt.SentCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 4 {
+		t.Errorf("sent %d messages, want the init message and 3 codes", len(sent))
+	}
+}
+
+func TestCancelKeepsConnectionUsable(t *testing.T) {
+	cc, mt := connectMock(t)
+
+	var late string
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cc.DoRaw(ctx, map[string]string{"command": "First"}, &late)
+	}()
+	// Wait for the command to be sent before giving up on it
+	for len(mt.Sent()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled DoRaw() = %v, want context.Canceled", err)
+	}
+
+	if err := mt.EnqueueResult("late"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.EnqueueResult("second"); err != nil {
+		t.Fatal(err)
+	}
+	var result string
+	if err := cc.DoRaw(context.Background(), map[string]string{"command": "Second"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result != "second" {
+		t.Errorf("DoRaw() result = %q, want the response to the second command", result)
+	}
+	if late != "" {
+		t.Errorf("late response was written to the abandoned result: %q", late)
+	}
+}
+
+func TestCloseDrainsPending(t *testing.T) {
+	cc, mt := connectMock(t)
+
+	rc, err := cc.PerformCodeAsync(testCode(t, "M115"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan error, 1)
+	go func() { closed <- cc.Close() }()
+
+	// New commands are rejected as soon as Close has been called
+	for !cc.isClosing() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err = cc.PerformCode(testCode(t, "M114")); !errors.Is(err, ErrConnectionClosing) {
+		t.Errorf("PerformCode() after Close = %v, want ErrConnectionClosing", err)
+	}
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the pending code finished")
+	default:
+	}
+
+	if err = mt.EnqueueResult(codeReply("drained")); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-rc; r.Err != nil || r.Result.FullText() != "drained" {
+		t.Errorf("pending code got %v, %v", r.Result, r.Err)
+	}
+	if err = <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if !mt.IsClosed() {
+		t.Error("transport was not closed")
+	}
+}
+
+func TestCloseTimeoutFailsPending(t *testing.T) {
+	cc, mt := connectMock(t)
+	cc.CloseTimeout = 10 * time.Millisecond
+
+	rc, err := cc.PerformCodeAsync(testCode(t, "M115"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-rc; !errors.Is(r.Err, ErrConnectionClosing) {
+		t.Errorf("pending code got %v, want ErrConnectionClosing", r.Err)
+	}
+	if !mt.IsClosed() {
+		t.Error("transport was not closed")
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(mt *mock.Transport) error
+		kind    error
+		is      error
+	}{
+		{
+			name:    "Command",
+			respond: func(mt *mock.Transport) error { return mt.EnqueueError("InvalidOperationException", "Failed") },
+			kind:    ErrCommand,
+		},
+		{
+			name:    "Cancelled",
+			respond: func(mt *mock.Transport) error { return mt.EnqueueError(TaskCanceledException, "Cancelled") },
+			kind:    ErrCommand,
+			is:      ErrCodeCancelled,
+		},
+		{
+			name: "Malformed",
+			respond: func(mt *mock.Transport) error {
+				mt.EnqueueJSON(`{"success":true,"result":`)
+				return nil
+			},
+			kind: ErrProtocol,
+		},
+		{
+			name:    "WrongType",
+			respond: func(mt *mock.Transport) error { return mt.EnqueueResult(map[string]int{"a": 1}) },
+			kind:    ErrProtocol,
+		},
+		{
+			name:    "Transport",
+			respond: func(mt *mock.Transport) error { return mt.Close() },
+			kind:    ErrTransport,
+		},
+	}
+	kinds := []error{ErrCommand, ErrProtocol, ErrTransport}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc, mt := connectMock(t)
+			if err := tt.respond(mt); err != nil {
+				t.Fatal(err)
+			}
+			var result string
+			err := cc.DoRaw(context.Background(), map[string]string{"command": "Test"}, &result)
+			if err == nil {
+				t.Fatal("DoRaw() succeeded")
+			}
+			for _, k := range kinds {
+				if errors.Is(err, k) != (k == tt.kind) {
+					t.Errorf("errors.Is(%v, %v) = %t", err, k, k != tt.kind)
+				}
+			}
+			var e Error
+			if !errors.As(err, &e) || e.Kind() != tt.kind {
+				t.Errorf("%v is not classified as %v", err, tt.kind)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("%v does not match %v", err, tt.is)
+			}
+		})
+	}
+}
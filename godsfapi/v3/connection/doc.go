@@ -3,5 +3,11 @@ Package connection provides implementations for all connection types.
 
 The zero value of each connection type is ready to initiate a connection and on
 success can be used for further interaction with DuetControlServer.
+
+All connections exchange their messages via a ConnectionTransport. Connect uses a
+transport on top of a UNIX or TCP socket while ConnectTransport accepts any other
+implementation, e.g. the in-memory one from package mock to test code without a
+running DuetControlServer. The only exception is CodeStreamConnection which switches
+to plain text lines after connecting and therefore needs a socket.
 */
 package connection
@@ -0,0 +1,40 @@
+package connection
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBufferSize is the capacity up to which encode buffers are reused.
+// Larger ones (e.g. after sending a big patch) are left to the garbage collector.
+const maxPooledBufferSize = 64 * 1024
+
+// encodeBuffers holds the buffers outgoing messages are encoded into
+var encodeBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// encode serializes data into a buffer from the pool that has to be released via releaseBuffer
+func (bc *BaseConnection) encode(data interface{}) (*bytes.Buffer, error) {
+	buf := encodeBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	enc := json.NewEncoder(buf)
+	if bc.IndentJSON {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(data); err != nil {
+		releaseBuffer(buf)
+		return nil, err
+	}
+	// Encode terminates every value with a newline which json.Marshal does not
+	buf.Truncate(buf.Len() - 1)
+	return buf, nil
+}
+
+// releaseBuffer returns a buffer to the pool
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufferSize {
+		encodeBuffers.Put(buf)
+	}
+}
@@ -0,0 +1,71 @@
+package connection
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// benchmarkCode returns a typical code as it is sent in a tight loop
+func benchmarkCode(b *testing.B) *commands.Code {
+	c, err := commands.ParseCode("G1 X10.5 Y20 Z0.3 E1.25 F3000")
+	if err != nil {
+		b.Fatal(err)
+	}
+	c.Channel = types.SBC
+	return c
+}
+
+// BenchmarkEncode compares encoding a code into a pooled buffer with json.Marshal
+// which allocates a new slice for every message
+func BenchmarkEncode(b *testing.B) {
+	c := benchmarkCode(b)
+	var bc BaseConnection
+
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, err := bc.encode(c)
+			if err != nil {
+				b.Fatal(err)
+			}
+			releaseBuffer(buf)
+		}
+	})
+
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := json.Marshal(c); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkPerformCode runs PerformCode in a tight loop against a mock transport
+// that replies to every code with the same result
+func BenchmarkPerformCode(b *testing.B) {
+	c := benchmarkCode(b)
+	resp, err := json.Marshal(commands.BaseResponse{Success: true, Result: []messages.Message{}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	var cc CommandConnection
+	if err := cc.ConnectTransport(initmessages.NewCommandInitMessage(), newBufferedTransport(resp)); err != nil {
+		b.Fatal(err)
+	}
+	defer cc.CloseNow()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cc.PerformCode(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTransport is matched by errors.Is for failures of the underlying socket, e.g. if the
+	// control server was stopped. Unlike other errors these can be retried once reconnected.
+	ErrTransport = errors.New("Transport failure")
+	// ErrProtocol is matched by errors.Is for malformed or unexpected messages from the control server
+	ErrProtocol = errors.New("Protocol violation")
+	// ErrCommand is matched by errors.Is if the control server received a command but rejected it
+	ErrCommand = errors.New("Command failed")
+)
+
+// Error is implemented by all errors classifying a failure as ErrTransport, ErrProtocol or ErrCommand.
+// The classic way to check the kind is errors.Is(err, ErrTransport) though.
+type Error interface {
+	error
+	// Kind returns one of ErrTransport, ErrProtocol or ErrCommand
+	Kind() error
+}
+
+// TransportError is returned if the socket to the control server failed or was closed
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Kind() error { return ErrTransport }
+
+func (e *TransportError) Is(target error) bool { return target == ErrTransport }
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+func (e *TransportError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("Transport failure: %v", e.Err)
+}
+
+// CommandError is returned if the control server responded to a command with an error
+type CommandError struct {
+	// Command is the name of the failed command
+	Command string
+	// Type is the type of the exception thrown by the control server
+	Type string
+	// Reason is the message of the exception thrown by the control server
+	Reason string
+}
+
+func (e *CommandError) Kind() error { return ErrCommand }
+
+func (e *CommandError) Is(target error) bool { return target == ErrCommand }
+
+func (e *CommandError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("InternalServerError: %s, %s, %s", e.Command, e.Type, e.Reason)
+}
+
+// transportError wraps err as TransportError unless it is one already
+func transportError(err error) error {
+	var te *TransportError
+	if errors.As(err, &te) {
+		return err
+	}
+	return &TransportError{Err: err}
+}
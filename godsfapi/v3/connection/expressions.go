@@ -0,0 +1,94 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// ExpressionErrors is returned by EvaluateExpressions if at least one expression could not be evaluated.
+// It holds one entry per expression in the same order which is nil for expressions that succeeded.
+type ExpressionErrors []error
+
+func (e ExpressionErrors) Error() string {
+	var first error
+	failed := 0
+	for _, err := range e {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("%d of %d expressions failed, first error: %v", failed, len(e), first)
+}
+
+// EvaluateExpressions evaluates multiple expressions on the given channel and returns their results
+// in the same order
+func (bcc *BaseCommandConnection) EvaluateExpressions(channel types.CodeChannel, expressions []string) ([]interface{}, error) {
+	return bcc.EvaluateExpressionsCtx(context.Background(), channel, expressions)
+}
+
+// EvaluateExpressionsCtx evaluates multiple expressions on the given channel and returns their results
+// in the same order or stops waiting for them once ctx is done.
+// All expressions are sent without waiting for the previous responses so they only cost a single round-trip.
+// If some of the expressions fail the results of the others are still returned alongside
+// an ExpressionErrors error holding the individual errors. Connection failures are returned as they are.
+func (bcc *BaseCommandConnection) EvaluateExpressionsCtx(ctx context.Context, channel types.CodeChannel, expressions []string) ([]interface{}, error) {
+	results := make([]interface{}, len(expressions))
+	errs := make(ExpressionErrors, len(expressions))
+	var connErr error
+
+	// Receive responses concurrently like ResolvePaths so the control server is never blocked writing them
+	type pending struct {
+		index   int
+		r       *request
+		command *commands.EvaluateExpression
+	}
+	pc := make(chan pending, len(expressions))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range pc {
+			resp, err := bcc.awaitCommand(ctx, p.r, p.command, nil)
+			if err != nil {
+				// Only errors without a response are caused by the connection
+				if resp == nil && connErr == nil {
+					connErr = err
+				}
+				errs[p.index] = fmt.Errorf("%s: %w", expressions[p.index], err)
+				continue
+			}
+			results[p.index] = resp.GetResult()
+		}
+	}()
+
+	var sendErr error
+	for i, e := range expressions {
+		ee := commands.NewEvaluateExpression(channel, e)
+		r, err := bcc.sendRequest(ee)
+		if err != nil {
+			sendErr = err
+			break
+		}
+		pc <- pending{index: i, r: r, command: ee}
+	}
+	close(pc)
+	<-done
+
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	if connErr != nil {
+		return nil, connErr
+	}
+	for _, err := range errs {
+		if err != nil {
+			return results, errs
+		}
+	}
+	return results, nil
+}
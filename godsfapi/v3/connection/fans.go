@@ -0,0 +1,65 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/fans"
+)
+
+const (
+	// maxFanPwm is the largest fan value on the legacy 0-255 scale
+	maxFanPwm = 255
+	// fanSpeedTolerance is the maximum difference between a requested and a reported fan value
+	fanSpeedTolerance = 0.005
+)
+
+// SetFanSpeed sets the requested value of the given fan (M106) and waits until the object model
+// reports it. The speed is expected as a fraction between 0 and 1; values above 1 are interpreted
+// on the legacy 0-255 scale and converted. An error is returned if the fan does not exist.
+func (bcc *BaseCommandConnection) SetFanSpeed(index int, fraction float64) error {
+	if fraction < 0 || fraction > maxFanPwm || math.IsNaN(fraction) {
+		return fmt.Errorf("Invalid fan speed %v", fraction)
+	}
+	if fraction > 1 {
+		fraction /= maxFanPwm
+	}
+	ctx := context.Background()
+	if _, err := bcc.fan(ctx, index); err != nil {
+		return err
+	}
+	code := fmt.Sprintf("M106 P%d S%s", index, strconv.FormatFloat(fraction, 'f', -1, 64))
+	if err := bcc.performJobCode(ctx, code); err != nil {
+		return err
+	}
+	return bcc.awaitModel(func() (bool, error) {
+		f, err := bcc.fan(ctx, index)
+		if err != nil {
+			return false, err
+		}
+		return math.Abs(f.RequestedValue-fraction) <= fanSpeedTolerance, nil
+	})
+}
+
+// GetFanSpeed returns the requested value of the given fan as a fraction between 0 and 1
+func (bcc *BaseCommandConnection) GetFanSpeed(index int) (float64, error) {
+	f, err := bcc.fan(context.Background(), index)
+	if err != nil {
+		return 0, err
+	}
+	return f.RequestedValue, nil
+}
+
+// fan queries the given fan from the object model
+func (bcc *BaseCommandConnection) fan(ctx context.Context, index int) (*fans.Fan, error) {
+	var fs []*fans.Fan
+	if err := bcc.GetObjectModelKeyCtx(ctx, "fans", &fs); err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(fs) || fs[index] == nil {
+		return nil, fmt.Errorf("Fan %d does not exist", index)
+	}
+	return fs[index], nil
+}
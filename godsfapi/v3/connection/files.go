@@ -0,0 +1,104 @@
+package connection
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ProgressFunc is called during file transfers with the number of bytes transferred so far
+// and the total size of the transfer or -1 if unknown
+type ProgressFunc func(transferred, total int64)
+
+// progressWriter counts the bytes written and reports them to a ProgressFunc
+type progressWriter struct {
+	w           io.Writer
+	transferred int64
+	total       int64
+	progress    ProgressFunc
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.transferred += int64(n)
+	if pw.progress != nil {
+		pw.progress(pw.transferred, pw.total)
+	}
+	return n, err
+}
+
+// requireSharedFileSystem returns an error wrapping ErrNoSharedFileSystem if this connection was
+// established over the network so files resolved by the control server are not accessible
+func (bcc *BaseCommandConnection) requireSharedFileSystem(operation string) error {
+	if bcc.network != "" && bcc.network != "unix" {
+		return fmt.Errorf("Cannot %s over %s connection: %w", operation, bcc.network, ErrNoSharedFileSystem)
+	}
+	return nil
+}
+
+// UploadFile writes the content of r to the given RepRapFirmware-style path (e.g. 0:/gcodes/part.g).
+// The control server has no commands for file transfers so the path is resolved using ResolvePath
+// and written directly which requires this client to run on the SBC. An error wrapping
+// ErrNoSharedFileSystem is returned for connections established via NetworkConnect.
+// The content is streamed to a temporary file that replaces the target once complete so
+// readers never see partial files.
+// Missing parent directories are created. progress is optional and may be nil.
+func (bcc *BaseCommandConnection) UploadFile(remotePath string, r io.Reader, progress ProgressFunc) error {
+	if err := bcc.requireSharedFileSystem("upload " + remotePath); err != nil {
+		return err
+	}
+	path, err := bcc.ResolvePath(remotePath)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(&progressWriter{w: f, total: -1, progress: progress}, r)
+	if err == nil {
+		// DCS may be running as a different user
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// DownloadFile writes the content of the file at the given RepRapFirmware-style path (e.g. 0:/sys/config.g) to w.
+// Like UploadFile this resolves the path using ResolvePath and reads the file directly,
+// so it fails with an error wrapping ErrNoSharedFileSystem on network connections.
+// progress is optional and may be nil.
+func (bcc *BaseCommandConnection) DownloadFile(remotePath string, w io.Writer, progress ProgressFunc) error {
+	if err := bcc.requireSharedFileSystem("download " + remotePath); err != nil {
+		return err
+	}
+	path, err := bcc.ResolvePath(remotePath)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	total := int64(-1)
+	if fi, err := f.Stat(); err == nil {
+		total = fi.Size()
+	}
+	_, err = io.Copy(&progressWriter{w: w, total: total, progress: progress}, f)
+	return err
+}
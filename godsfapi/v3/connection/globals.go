@@ -0,0 +1,83 @@
+package connection
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// variableName matches valid names of meta G-code variables
+var variableName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// SetGlobalVariable sets the global variable with the given name (without "global.") to value.
+// The variable is created using "global" if it does not exist yet and updated using "set" otherwise.
+// Supported values are strings, bools, integers and floats. Integers and floats are written so that
+// the firmware keeps their type, e.g. float64(1) is stored as 1.0 and not as integer 1.
+func (bcc *BaseCommandConnection) SetGlobalVariable(name string, value interface{}) error {
+	if !variableName.MatchString(name) {
+		return fmt.Errorf("Invalid variable name %q", name)
+	}
+	expr, err := metaValue(value)
+	if err != nil {
+		return err
+	}
+	exists, err := bcc.EvaluateExpressionAsBool(types.SBC, fmt.Sprintf("exists(global.%s)", name))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("global %s = %s", name, expr)
+	if exists {
+		code = fmt.Sprintf("set global.%s = %s", name, expr)
+	}
+	result, err := bcc.PerformSimpleCode(code, types.SBC)
+	if err != nil {
+		return err
+	}
+	for _, m := range (messages.Message{Content: result}).Split() {
+		if m.Type == messages.Error {
+			return fmt.Errorf("Failed to set global.%s: %s", name, m.Content)
+		}
+	}
+	return nil
+}
+
+// GetGlobalVariable returns the value of the global variable with the given name (without "global.").
+// Like EvaluateExpression numbers are returned as float64.
+func (bcc *BaseCommandConnection) GetGlobalVariable(name string) (interface{}, error) {
+	if !variableName.MatchString(name) {
+		return nil, fmt.Errorf("Invalid variable name %q", name)
+	}
+	return bcc.EvaluateExpression(types.SBC, "global."+name)
+}
+
+// metaValue converts a value to its representation in meta G-code
+func metaValue(value interface{}) (string, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return `"` + strings.ReplaceAll(v.String(), `"`, `""`) + `"`, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("Unsupported value %v", f)
+		}
+		s := strconv.FormatFloat(f, 'f', -1, v.Type().Bits())
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	}
+	return "", fmt.Errorf("Unsupported variable type %T", value)
+}
@@ -0,0 +1,141 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/heat"
+)
+
+// heaterPollInterval is the delay between two queries of the heaters
+const heaterPollInterval = time.Second
+
+var (
+	// ErrHeaterTuningFailed is returned by TuneHeater if tuning ended without a new heater model
+	ErrHeaterTuningFailed = errors.New("Heater tuning was aborted or did not complete")
+	// ErrHeaterFault is returned by TuneHeater and WaitForHeaterTemperature if the heater faulted
+	ErrHeaterFault = errors.New("Heater fault")
+)
+
+// HeaterTuningResult holds the parameters determined by tuning a heater
+type HeaterTuningResult struct {
+	// Heater is the number of the tuned heater
+	Heater int
+	// Model is the new heater model including the computed PID parameters.
+	// It is only kept until the next reset unless it is saved using M500 or added to config.g as M307.
+	Model heat.HeaterModel
+}
+
+// TuneHeater runs the heater tuning process (M303) for the given heater and target temperature
+// and waits for it to finish. See TuneHeaterCtx.
+func (bcc *BaseCommandConnection) TuneHeater(heater int, target float64) (*HeaterTuningResult, error) {
+	return bcc.TuneHeaterCtx(context.Background(), heater, target)
+}
+
+// TuneHeaterCtx runs the heater tuning process (M303) for the given heater and target temperature
+// and waits until it has finished or ctx is done. Tuning usually takes several minutes.
+// RepRapFirmware reports the result as a message; instead of parsing it the resulting heater
+// model is read back from the object model. ErrHeaterFault is returned if the heater faulted and
+// ErrHeaterTuningFailed if tuning ended without updating the heater model, e.g. if it was aborted.
+func (bcc *BaseCommandConnection) TuneHeaterCtx(ctx context.Context, heater int, target float64) (*HeaterTuningResult, error) {
+	before, err := bcc.heater(ctx, heater)
+	if err != nil {
+		return nil, err
+	}
+	if target <= 0 || (before.Max > 0 && target > before.Max) {
+		return nil, fmt.Errorf("Invalid tuning target %.1fC for heater %d", target, heater)
+	}
+
+	code := fmt.Sprintf("M303 H%d S%s", heater, strconv.FormatFloat(target, 'f', -1, 64))
+	if err = bcc.performJobCode(ctx, code); err != nil {
+		return nil, err
+	}
+
+	// The heater state may lag behind M303 so wait for tuning to start before waiting for it to end
+	startCtx, cancel := context.WithTimeout(ctx, modelConfirmTimeout)
+	_, err = bcc.awaitHeater(startCtx, heater, func(h *heat.Heater) bool {
+		return h.State != nil && (*h.State == heat.Tuning || *h.State == heat.Fault)
+	})
+	cancel()
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("Heater %d is still being tuned: %w", heater, ctx.Err())
+	}
+
+	h, err := bcc.awaitHeater(ctx, heater, func(h *heat.Heater) bool {
+		return h.State == nil || *h.State != heat.Tuning
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("Heater %d is still being tuned: %w", heater, ctx.Err())
+		}
+		return nil, err
+	}
+	if h.State != nil && *h.State == heat.Fault {
+		return nil, fmt.Errorf("Failed to tune heater %d: %w", heater, ErrHeaterFault)
+	}
+	if reflect.DeepEqual(h.Model, before.Model) {
+		return nil, fmt.Errorf("Failed to tune heater %d: %w", heater, ErrHeaterTuningFailed)
+	}
+	return &HeaterTuningResult{Heater: heater, Model: h.Model}, nil
+}
+
+// awaitHeater polls the given heater until done returns true for it or ctx is done
+func (bcc *BaseCommandConnection) awaitHeater(ctx context.Context, heater int, done func(*heat.Heater) bool) (*heat.Heater, error) {
+	for {
+		h, err := bcc.heater(ctx, heater)
+		if err != nil {
+			return nil, err
+		}
+		if done(h) {
+			return h, nil
+		}
+		select {
+		case <-ctx.Done():
+			return h, ctx.Err()
+		case <-time.After(heaterPollInterval):
+		}
+	}
+}
+
+// WaitForHeaterTemperature polls the given heater until its current temperature is within tolerance of target
+// or ctx is done. On timeout the returned error wraps ctx.Err() and names the last observed temperature.
+// An error wrapping ErrHeaterFault is returned if the heater faults while waiting.
+func (bcc *BaseCommandConnection) WaitForHeaterTemperature(ctx context.Context, heater int, target, tolerance float64) error {
+	if tolerance < 0 {
+		return fmt.Errorf("Invalid tolerance %v", tolerance)
+	}
+	for {
+		h, err := bcc.heater(ctx, heater)
+		if err != nil {
+			return err
+		}
+		if h.State != nil && *h.State == heat.Fault {
+			return fmt.Errorf("Heater %d at %.1fC: %w", heater, h.Current, ErrHeaterFault)
+		}
+		if math.Abs(h.Current-target) <= tolerance {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Heater %d is still at %.1fC instead of %.1fC: %w", heater, h.Current, target, ctx.Err())
+		case <-time.After(heaterPollInterval):
+		}
+	}
+}
+
+// heater queries the given heater from the object model
+func (bcc *BaseCommandConnection) heater(ctx context.Context, heater int) (*heat.Heater, error) {
+	var h heat.Heat
+	if err := bcc.GetObjectModelKeyCtx(ctx, "heat", &h); err != nil {
+		return nil, err
+	}
+	if heater < 0 || heater >= len(h.Heaters) {
+		return nil, fmt.Errorf("Heater %d does not exist", heater)
+	}
+	return &h.Heaters[heater], nil
+}
@@ -1,8 +1,8 @@
 package connection
 
 import (
+	"errors"
 	"net"
-
 	"os"
 
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
@@ -34,18 +34,27 @@ type HttpEndpointUnixSocket struct {
 	socket net.Listener
 	// Handler to handle individiual requests
 	Handler HttpEndpointHandler
+	// IsUploadRequest is set if the body of requests is saved to a file whose path is passed instead
+	IsUploadRequest bool
+	// owner is the connection that created this endpoint or nil
+	owner *BaseCommandConnection
 }
 
 // NewHttpEndpointUnixSocket opens a new UNIX socket on the given file path
 func NewHttpEndpointUnixSocket(t httpendpoints.HttpEndpointType, ns, path, socketPath string, backlog uint64) (*HttpEndpointUnixSocket, error) {
-	h := HttpEndpointUnixSocket{
+	return newHttpEndpointUnixSocket(&HttpEndpointUnixSocket{
 		EndpointType: t,
 		Namespace:    ns,
 		EndpointPath: path,
 		SocketPath:   socketPath,
-	}
+	})
+}
+
+// newHttpEndpointUnixSocket opens the UNIX socket of the given endpoint and starts accepting connections.
+// Fields like the Handler have to be set before so early requests are not rejected.
+func newHttpEndpointUnixSocket(h *HttpEndpointUnixSocket) (*HttpEndpointUnixSocket, error) {
 	err := os.Remove(h.SocketPath)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
 
@@ -53,9 +62,9 @@ func NewHttpEndpointUnixSocket(t httpendpoints.HttpEndpointType, ns, path, socke
 	if err != nil {
 		return nil, err
 	}
-	go h.accept()
+	go h.accept(h.socket)
 
-	return &h, nil
+	return h, nil
 }
 
 // Close the socket connection and remove the corresponding socket file
@@ -65,17 +74,33 @@ func (h *HttpEndpointUnixSocket) Close() error {
 	}
 	err := h.socket.Close()
 	h.socket = nil
+	if rerr := os.Remove(h.SocketPath); err == nil && rerr != nil && !os.IsNotExist(rerr) {
+		err = rerr
+	}
+	return err
+}
+
+// Unregister removes this endpoint from the control server and closes its socket.
+// This is only supported for endpoints created via BaseCommandConnection.AddHttpEndpoint
+// or one of its variants.
+func (h *HttpEndpointUnixSocket) Unregister() error {
+	if h.owner == nil {
+		return errors.New("HTTP endpoint was not created by a connection")
+	}
+	_, err := h.owner.RemoveHttpEndpoint(h.EndpointType, h.Namespace, h.EndpointPath)
 	return err
 }
 
 // accept accepts incoming UNIX socket connections and forwards
-// them to a handler
-func (h *HttpEndpointUnixSocket) accept() {
+// them to a handler until the listener is closed
+func (h *HttpEndpointUnixSocket) accept(l net.Listener) {
 	for {
-		c, err := h.socket.Accept()
+		c, err := l.Accept()
 		if err != nil {
-			// TODO: instead return?
-			continue
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
 		}
 		hec := NewHttpEndpointConnection(c, h.EndpointType == httpendpoints.WebSocket)
 		if h.Handler != nil {
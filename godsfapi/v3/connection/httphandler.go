@@ -0,0 +1,143 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/httpendpoints"
+)
+
+// sessionIdKey is the context key holding the session ID of a request
+type sessionIdKey struct{}
+
+// HttpSessionId returns the ID of the user session a request passed to a handler created by
+// NewHttpHandler belongs to. It is -1 for anonymous requests.
+func HttpSessionId(r *http.Request) (int64, bool) {
+	id, ok := r.Context().Value(sessionIdKey{}).(int64)
+	return id, ok
+}
+
+// httpHandler adapts a http.Handler to a HttpEndpointHandler
+type httpHandler struct {
+	handler http.Handler
+}
+
+// NewHttpHandler creates a HttpEndpointHandler that serves requests of a custom HTTP endpoint using
+// a standard http.Handler. Method, path, query parameters, headers and body of the request are
+// populated from the received request. For upload endpoints the body is read from the file that
+// DuetWebServer saved it to.
+//
+// Only the status code and the body of the response are passed on to the client. The response
+// type is derived from the Content-Type header: JSON for application/json, plain text otherwise
+// or a bare status code if no body has been written. Other response headers are not supported.
+func NewHttpHandler(handler http.Handler) HttpEndpointHandler {
+	return &httpHandler{handler: handler}
+}
+
+// AddHttpHandler adds a new third-party HTTP endpoint in the format /machine/{ns}/{path}
+// whose requests are served by the given http.Handler (see NewHttpHandler)
+func (bcc *BaseCommandConnection) AddHttpHandler(t httpendpoints.HttpEndpointType, ns, path string, isUploadRequest bool, handler http.Handler) (*HttpEndpointUnixSocket, error) {
+	return bcc.AddHttpEndpointHandler(t, ns, path, isUploadRequest, NewHttpHandler(handler))
+}
+
+// Handle a single request received from DuetWebServer
+func (hh *httpHandler) Handle(h *HttpEndpointUnixSocket, c *HttpEndpointConnection) {
+	rhr, err := c.ReadRequest()
+	if err != nil {
+		c.Close()
+		return
+	}
+	req, err := newHttpRequest(h, rhr)
+	if err != nil {
+		c.SendResponse(http.StatusInternalServerError, err.Error(), commands.PlainText)
+		return
+	}
+	defer req.Body.Close()
+
+	rw := &httpResponseWriter{header: make(http.Header)}
+	hh.handler.ServeHTTP(rw, req)
+
+	statusCode := rw.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	responseType := commands.HttpResponseType(commands.PlainText)
+	if rw.body.Len() == 0 {
+		responseType = commands.StatusCode
+	} else if mt, _, err := mime.ParseMediaType(rw.header.Get("Content-Type")); err == nil && mt == "application/json" {
+		responseType = commands.JSON
+	}
+	c.SendResponse(uint16(statusCode), rw.body.String(), responseType)
+}
+
+// newHttpRequest converts a received request to a http.Request
+func newHttpRequest(h *HttpEndpointUnixSocket, rhr *commands.ReceivedHttpRequest) (*http.Request, error) {
+	var body io.ReadCloser
+	if h.IsUploadRequest {
+		f, err := os.Open(rhr.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = f
+	} else {
+		body = ioutil.NopCloser(strings.NewReader(rhr.Body))
+	}
+
+	path := "/machine/" + h.Namespace + "/" + h.EndpointPath
+	if h.Namespace == httpendpoints.RepRapFirmwareNamespace {
+		path = "/" + h.Namespace + h.EndpointPath
+	}
+	query := make(url.Values, len(rhr.Queries))
+	for k, v := range rhr.Queries {
+		query.Set(k, v)
+	}
+	u := &url.URL{Path: path, RawQuery: query.Encode()}
+
+	ctx := context.WithValue(context.Background(), sessionIdKey{}, rhr.SessionId)
+	req, err := http.NewRequest(string(h.EndpointType), u.String(), body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.RequestURI = u.RequestURI()
+	for k, v := range rhr.Headers {
+		req.Header.Set(k, v)
+	}
+	if rhr.ContentType != "" {
+		req.Header.Set("Content-Type", rhr.ContentType)
+	}
+	return req, nil
+}
+
+// httpResponseWriter buffers the response of a http.Handler
+type httpResponseWriter struct {
+	header     http.Header
+	body       bytes.Buffer
+	statusCode int
+}
+
+func (rw *httpResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *httpResponseWriter) Write(b []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusOK
+	}
+	return rw.body.Write(b)
+}
+
+func (rw *httpResponseWriter) WriteHeader(statusCode int) {
+	if rw.statusCode == 0 {
+		rw.statusCode = statusCode
+	}
+}
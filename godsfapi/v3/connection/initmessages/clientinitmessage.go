@@ -17,6 +17,9 @@ const (
 	// ConnectionModeSubscribe enters subscription mode. In this mode object model updates are
 	// transmitted to the client after each update
 	ConnectionModeSubscribe = "Subscribe"
+	// ConnectionModeCodeStream enters code stream mode. In this mode G/M/T-codes are sent as
+	// plain text lines and the replies are returned the same way
+	ConnectionModeCodeStream = "CodeStream"
 )
 
 // ClientInitMessage is sent from the client to the server as response
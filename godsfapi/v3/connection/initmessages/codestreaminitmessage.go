@@ -0,0 +1,32 @@
+package initmessages
+
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+
+// DefaultCodeStreamBufferSize is the default number of codes the control server executes
+// concurrently on a code stream connection
+const DefaultCodeStreamBufferSize = 32
+
+// CodeStreamInitMessage enters code stream mode. Once established codes are sent as plain text
+// lines instead of JSON commands and the code replies are returned as plain text lines.
+// This mode is only supported by control servers of version 3.3 or newer.
+type CodeStreamInitMessage struct {
+	BaseInitMessage
+	// BufferSize is the maximum number of codes being executed simultaneously
+	BufferSize int
+	// Channel to execute the codes on
+	Channel types.CodeChannel
+}
+
+// NewCodeStreamInitMessage creates a new CodeStreamInitMessage for the given channel
+func NewCodeStreamInitMessage(bufferSize int, channel types.CodeChannel) ClientInitMessage {
+	return &CodeStreamInitMessage{
+		BaseInitMessage: NewBaseInitMessage(ConnectionModeCodeStream),
+		BufferSize:      bufferSize,
+		Channel:         channel,
+	}
+}
+
+// Validate checks if the channel is valid
+func (csim *CodeStreamInitMessage) Validate() error {
+	return csim.Channel.Validate()
+}
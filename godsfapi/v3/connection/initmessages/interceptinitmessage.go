@@ -6,16 +6,28 @@ import "github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
 type InterceptionMode string
 
 const (
-	// InterceptionModePre intercepts codes before they are internally processed by the control server
+	// InterceptionModePre intercepts codes before they are internally processed by the control server.
+	// This is the right mode to implement new codes or to replace existing ones.
 	InterceptionModePre InterceptionMode = "Pre"
 	// InterceptionModePost intercepts codes after the initial processing of the control server
-	// but before they are forwarded to the RepRapFirmware controller
-	InterceptionModePost = "Post"
+	// but before they are forwarded to the RepRapFirmware controller. Codes handled by the
+	// control server itself (e.g. M98 or file related codes) do not get here.
+	InterceptionModePost InterceptionMode = "Post"
 	// InterceptionModeExecuted receives notifications for executed codes. In this state the final
-	// result can still be changed
-	InterceptionModeExecuted = "Executed"
+	// result can still be changed by resolving the code
+	InterceptionModeExecuted InterceptionMode = "Executed"
 )
 
+// IsValid checks if this is one of the known interception modes
+func (m InterceptionMode) IsValid() bool {
+	switch m {
+	case InterceptionModePre, InterceptionModePost, InterceptionModeExecuted:
+		return true
+	default:
+		return false
+	}
+}
+
 // InterceptInitMessage enters interception mode. Whenever a code is received the connection must respons with
 // one of
 // - commands.Ignore to pass through the code without modifications (i.e. it is ignored by the client)
@@ -34,6 +46,7 @@ type InterceptInitMessage struct {
 	// This may only specify the code type and major/minor number (e.g. G1 or M105).
 	// Alternatively keyword types may be specified (e.g. if or elif).
 	// Asterisks are supported, tool (e.g. T*)
+	// If the list is empty, all codes are intercepted
 	Filters []string
 	// PriorityCodes defines if priority codes may be intercepted (e.g. M112, M122, M999)
 	// See also CodeType.IsPrioritized
@@ -50,3 +63,13 @@ func NewInterceptInitMessage(iMode InterceptionMode, channels []types.CodeChanne
 		PriorityCodes:    priorityCodes,
 	}
 }
+
+// Validate checks if all channels are valid
+func (iim *InterceptInitMessage) Validate() error {
+	for _, c := range iim.Channels {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package initmessages
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+
 const (
 	// ProcotolVersion is the version the server needs to have to be compatible with
 	// this client
@@ -16,6 +18,9 @@ type ServerInitMessage struct {
 	Version int64
 	// Id is the unique connection ID assigned by the control server to allow clients to track their commands
 	Id int64
+	// Permissions granted to this connection. Only sent by control servers that report them,
+	// nil otherwise.
+	Permissions []types.SbcPermissions `json:",omitempty"`
 }
 
 // IsCompatible checks if the returned server API version is compatible with this client
@@ -1,6 +1,10 @@
 package connection
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
@@ -27,11 +31,18 @@ type InterceptConnection struct {
 }
 
 // Connect sends a InterceptInitMessage to the control server
-// mode is the initmessages.InterceptionMode
+// mode is the initmessages.InterceptionMode and selects when codes are intercepted (Pre, Post or Executed)
 // channels is an optional list of input channels to intercept codes from (empty list = all)
-// filters to filter specific codes (see initmessages.InterceptInitMessage for details)
+// filters to filter specific codes (e.g. "M587" or "G1", see initmessages.InterceptInitMessage for details).
+// Only matching codes are forwarded by the control server while every other code passes without delay.
+// Leaving filters empty intercepts all codes which is costly since every move has to pass
+// through this connection, so keep filters as narrow as possible. Every code that is received still
+// has to be answered by either CancelCode(), IgnoreCode(), ResolveCode() or ReplaceCode().
 // priorityCodes to enable codes with CodeFlags.IsPrioritized
 func (ic *InterceptConnection) Connect(mode initmessages.InterceptionMode, channels []types.CodeChannel, filters []string, priorityCodes bool, socketPath string) error {
+	if !mode.IsValid() {
+		return fmt.Errorf("Invalid interception mode %s", mode)
+	}
 	ic.Mode = mode
 	if len(channels) == 0 {
 		channels = types.AllChannels()
@@ -44,7 +55,7 @@ func (ic *InterceptConnection) Connect(mode initmessages.InterceptionMode, chann
 }
 
 // ReceiveCode waits for a code to be intercepted
-// Any other error than io.EOF requires the client to respond by either
+// Any other error than io.EOF (see errors.Is) requires the client to respond by either
 // CancelCode(), IgnoreCode() or ResolveCode() because DCS will otherwise
 // block while waiting for the Interceptor's response.
 func (ic *InterceptConnection) ReceiveCode() (*commands.Code, error) {
@@ -68,7 +79,75 @@ func (ic *InterceptConnection) IgnoreCode() error {
 }
 
 // ResolveCode instructs the control server to resolve the last received code with
-// the given message details
+// the given message details. Resolving with messages.Error marks the code as failed
+// which aborts a calling macro.
 func (ic *InterceptConnection) ResolveCode(mType messages.MessageType, content string) error {
 	return ic.Send(commands.NewResolve(mType, content))
 }
+
+// ResolveCodeMessage instructs the control server to resolve the last received code with
+// the given message. Only type and content of the message are transmitted.
+func (ic *InterceptConnection) ResolveCodeMessage(message messages.Message) error {
+	return ic.Send(commands.NewResolveMessage(message))
+}
+
+// ReplaceCode resolves the intercepted code by executing the given replacement codes instead of it.
+//
+// The replacements are parsed first and then executed one after another in the given order on the
+// channel of the intercepted code before the intercepted code is resolved. The channel remains blocked
+// by the intercepted code until then, so no other code of that channel runs in between and codes following
+// the intercepted one are only started once all replacements have finished. If the intercepted code
+// originates from a macro, the replacements inherit the IsFromMacro flag as described above.
+//
+// The messages produced by the replacements are combined to resolve the intercepted code. If a replacement
+// fails, the remaining replacements are skipped and the intercepted code is resolved with the error
+// so a calling macro is aborted like it would be for the original code. The intercepted code is always
+// resolved unless the connection itself fails.
+func (ic *InterceptConnection) ReplaceCode(intercepted *commands.Code, replacements ...string) error {
+	return ic.ReplaceCodeCtx(context.Background(), intercepted, replacements...)
+}
+
+// ReplaceCodeCtx is like ReplaceCode but stops waiting for replacements once ctx is done.
+// The intercepted code is cancelled in that case.
+func (ic *InterceptConnection) ReplaceCodeCtx(ctx context.Context, intercepted *commands.Code, replacements ...string) error {
+	codes := make([]*commands.Code, 0, len(replacements))
+	for _, r := range replacements {
+		c, err := commands.ParseCode(r)
+		if err != nil {
+			if rerr := ic.ResolveCode(messages.Error, fmt.Sprintf("Invalid replacement code %s: %v", r, err)); rerr != nil {
+				return rerr
+			}
+			return err
+		}
+		c.Channel = intercepted.Channel
+		c.Flags |= intercepted.Flags & commands.IsFromMacro
+		codes = append(codes, c)
+	}
+
+	var results []string
+	for _, c := range codes {
+		cr, err := ic.PerformCodeCtx(ctx, c)
+		if err != nil {
+			var rerr error
+			if ctx.Err() != nil {
+				rerr = ic.CancelCode()
+			} else if !isTransportError(err) {
+				rerr = ic.ResolveCode(messages.Error, err.Error())
+			}
+			if rerr != nil {
+				return rerr
+			}
+			return err
+		}
+		if cr == nil {
+			continue
+		}
+		if errs := cr.Errors(); len(errs) > 0 {
+			return ic.ResolveCode(messages.Error, cr.String())
+		}
+		if s := cr.String(); s != "" {
+			results = append(results, s)
+		}
+	}
+	return ic.ResolveCode(messages.Success, strings.Join(results, "\n"))
+}
@@ -0,0 +1,230 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/state"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+const (
+	// DefaultJobControlTimeout is the time PauseJob, ResumeJob and CancelJob wait for the
+	// machine status to change. Pausing may take a while since the current moves and pause.g
+	// have to finish first.
+	DefaultJobControlTimeout = 2 * time.Minute
+	// jobStatusPollInterval is the delay between two queries of the machine status
+	jobStatusPollInterval = 250 * time.Millisecond
+)
+
+// ErrNoJob is returned by job control functions if no file is being printed or simulated
+var ErrNoJob = errors.New("No job is being processed")
+
+// PauseJob pauses the current job using M25 and waits until the machine is paused
+// or DefaultJobControlTimeout has elapsed
+func (bcc *BaseCommandConnection) PauseJob() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultJobControlTimeout)
+	defer cancel()
+	return bcc.PauseJobCtx(ctx)
+}
+
+// PauseJobCtx pauses the current job using M25 and waits until the machine is paused or ctx is done.
+// It returns immediately if the job is already paused and ErrNoJob if there is nothing to pause.
+func (bcc *BaseCommandConnection) PauseJobCtx(ctx context.Context) error {
+	status, err := bcc.machineStatus(ctx)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case state.Paused:
+		return nil
+	case state.Processing, state.Simulating, state.Resuming:
+		if err = bcc.performJobCode(ctx, "M25"); err != nil {
+			return err
+		}
+	case state.Pausing:
+	default:
+		return ErrNoJob
+	}
+	return bcc.awaitStatus(ctx, func(s state.MachineStatus) bool { return s == state.Paused })
+}
+
+// ResumeJob resumes a paused job using M24 and waits until it is being processed again
+// or DefaultJobControlTimeout has elapsed
+func (bcc *BaseCommandConnection) ResumeJob() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultJobControlTimeout)
+	defer cancel()
+	return bcc.ResumeJobCtx(ctx)
+}
+
+// ResumeJobCtx resumes a paused job using M24 and waits until it is being processed again or ctx is done.
+// It returns immediately if the job is already running and ErrNoJob if there is nothing to resume.
+func (bcc *BaseCommandConnection) ResumeJobCtx(ctx context.Context) error {
+	status, err := bcc.machineStatus(ctx)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case state.Processing, state.Simulating:
+		return nil
+	case state.Pausing:
+		// M24 is rejected until the pause has completed
+		err = bcc.awaitStatus(ctx, func(s state.MachineStatus) bool { return s == state.Paused })
+		if err != nil {
+			return err
+		}
+		fallthrough
+	case state.Paused:
+		if err = bcc.performJobCode(ctx, "M24"); err != nil {
+			return err
+		}
+	case state.Resuming:
+	default:
+		return ErrNoJob
+	}
+	return bcc.awaitStatus(ctx, func(s state.MachineStatus) bool {
+		return s == state.Processing || s == state.Simulating
+	})
+}
+
+// CancelJob cancels the current job and waits until it has been stopped
+// or DefaultJobControlTimeout has elapsed
+func (bcc *BaseCommandConnection) CancelJob() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultJobControlTimeout)
+	defer cancel()
+	return bcc.CancelJobCtx(ctx)
+}
+
+// CancelJobCtx cancels the current job and waits until it has been stopped or ctx is done.
+// RepRapFirmware only cancels paused jobs so a running job is paused first (running pause.g)
+// before it is cancelled using M0 (running cancel.g). ErrNoJob is returned if there is nothing to cancel.
+func (bcc *BaseCommandConnection) CancelJobCtx(ctx context.Context) error {
+	status, err := bcc.machineStatus(ctx)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case state.Processing, state.Simulating, state.Resuming, state.Pausing:
+		if err = bcc.PauseJobCtx(ctx); err != nil {
+			return err
+		}
+	case state.Paused:
+	default:
+		return ErrNoJob
+	}
+	if err = bcc.performJobCode(ctx, "M0"); err != nil {
+		return err
+	}
+	return bcc.awaitStatus(ctx, func(s state.MachineStatus) bool {
+		switch s {
+		case state.Paused, state.Pausing, state.Resuming, state.Processing, state.Simulating:
+			return false
+		default:
+			return true
+		}
+	})
+}
+
+// EmergencyStop performs an emergency stop using M112 and waits until it has been acknowledged
+func (bcc *BaseCommandConnection) EmergencyStop(subscriptions ...*SubscribeConnection) error {
+	return bcc.EmergencyStopCtx(context.Background(), subscriptions...)
+}
+
+// EmergencyStopCtx performs an emergency stop using M112 and waits until it has been acknowledged
+// or ctx is done. The code is prioritized so it jumps all queued codes.
+//
+// The firmware is reset afterwards and the control server cancels all pending codes. This connection
+// remains usable but the given subscriptions are re-established (see SubscribeConnection.Reconnect)
+// once the stop was acknowledged so they do not miss the reset. Errors sending M112 are always returned.
+// Note that the machine is halted until M999 is sent.
+func (bcc *BaseCommandConnection) EmergencyStopCtx(ctx context.Context, subscriptions ...*SubscribeConnection) error {
+	c, err := commands.ParseCode("M112")
+	if err != nil {
+		return err
+	}
+	c.Channel = types.SBC
+	c.Flags |= commands.IsPrioritized
+	r, err := bcc.performCommand(ctx, c, nil)
+	// The code itself may be cancelled by the reset of the firmware
+	if err != nil && (r == nil || r.GetErrorType() != TaskCanceledException) {
+		return err
+	}
+	for _, sc := range subscriptions {
+		if err = sc.Reconnect(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// performJobCode executes a job control code on the SBC channel
+func (bcc *BaseCommandConnection) performJobCode(ctx context.Context, code string) error {
+	c, err := commands.ParseCode(code)
+	if err != nil {
+		return err
+	}
+	c.Channel = types.SBC
+	return bcc.performCodeChecked(ctx, c)
+}
+
+// performCodeChecked executes a code and converts an error message in its result to an error
+func (bcc *BaseCommandConnection) performCodeChecked(ctx context.Context, c *commands.Code) error {
+	cr, err := bcc.PerformCodeCtx(ctx, c)
+	if err != nil || cr == nil {
+		return err
+	}
+	if errs := cr.Errors(); len(errs) > 0 {
+		return fmt.Errorf("%s failed: %s", c.ShortString(), errs[0].Content)
+	}
+	return nil
+}
+
+// WaitForStatus polls the machine status until it matches one of the given targets or ctx is done.
+// On timeout the returned error wraps ctx.Err() and names the last observed status.
+func (bcc *BaseCommandConnection) WaitForStatus(ctx context.Context, targets ...state.MachineStatus) error {
+	if len(targets) == 0 {
+		return errors.New("No machine status to wait for")
+	}
+	return bcc.awaitStatus(ctx, func(s state.MachineStatus) bool {
+		for _, t := range targets {
+			if s == t {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// machineStatus queries the current machine status
+func (bcc *BaseCommandConnection) machineStatus(ctx context.Context) (state.MachineStatus, error) {
+	result, err := bcc.EvaluateExpressionCtx(ctx, types.SBC, "state.status")
+	if err != nil {
+		return "", err
+	}
+	s, ok := result.(string)
+	if !ok {
+		return "", conversionError("state.status", result, "string")
+	}
+	return state.MachineStatus(s), nil
+}
+
+// awaitStatus polls the machine status until accept returns true or ctx is done
+func (bcc *BaseCommandConnection) awaitStatus(ctx context.Context, accept func(s state.MachineStatus) bool) error {
+	for {
+		status, err := bcc.machineStatus(ctx)
+		if err != nil {
+			return err
+		}
+		if accept(status) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Machine status is still %s: %w", status, ctx.Err())
+		case <-time.After(jobStatusPollInterval):
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package connection
+
+import "log"
+
+// Logger receives diagnostic messages of a connection so they can be routed into an
+// existing logging setup. Messages start with the affected operation in angle brackets,
+// e.g. "<Reconnect> Attempt 2 failed: ...".
+type Logger interface {
+	// Debugf logs detailed messages such as every message sent and received.
+	// These are only generated if Debug is set on the connection.
+	Debugf(format string, args ...interface{})
+	// Infof logs regular events such as re-established connections
+	Infof(format string, args ...interface{})
+	// Warnf logs recoverable problems such as failed attempts to reconnect
+	Warnf(format string, args ...interface{})
+	// Errorf logs problems that could not be handled
+	Errorf(format string, args ...interface{})
+}
+
+// NopLogger discards all messages
+type NopLogger struct{}
+
+func (NopLogger) Debugf(format string, args ...interface{}) {}
+func (NopLogger) Infof(format string, args ...interface{})  {}
+func (NopLogger) Warnf(format string, args ...interface{})  {}
+func (NopLogger) Errorf(format string, args ...interface{}) {}
+
+// stdLogger is used if no Logger is set. Like before Logger was introduced it only writes errors
+// to the standard logger unless debug is set in which case all messages are written.
+type stdLogger struct {
+	debug bool
+}
+
+func (l stdLogger) Debugf(format string, args ...interface{}) {
+	if l.debug {
+		log.Printf("[DEBUG] "+format, args...)
+	}
+}
+
+func (l stdLogger) Infof(format string, args ...interface{}) {
+	if l.debug {
+		log.Printf("[INFO] "+format, args...)
+	}
+}
+
+func (l stdLogger) Warnf(format string, args ...interface{}) {
+	if l.debug {
+		log.Printf("[WARN] "+format, args...)
+	}
+}
+
+func (l stdLogger) Errorf(format string, args ...interface{}) {
+	log.Printf("[ERROR] "+format, args...)
+}
+
+// logger returns the Logger of this connection
+func (bc *BaseConnection) logger() Logger {
+	if bc.Logger != nil {
+		return bc.Logger
+	}
+	return stdLogger{debug: bc.Debug}
+}
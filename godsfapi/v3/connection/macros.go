@@ -0,0 +1,49 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// ErrMacroNotFound is returned by RunMacro if the macro file does not exist
+var ErrMacroNotFound = errors.New("Macro file not found")
+
+// RunMacro runs a macro file using M98 on the SBC channel and waits until it has finished
+// including all nested macros. Relative paths are resolved by the control server, usually
+// relative to the system directory.
+// An error is returned if the macro file does not exist (wrapping ErrMacroNotFound), if the macro
+// produced an error message or if it was cancelled (ErrFlushAborted).
+func (bcc *BaseCommandConnection) RunMacro(ctx context.Context, path string) error {
+	c, err := commands.ParseCode(fmt.Sprintf("M98 P\"%s\"", strings.ReplaceAll(path, "\"", "\"\"")))
+	if err != nil {
+		return err
+	}
+	c.Channel = types.SBC
+	cr, err := bcc.PerformCodeCtx(ctx, c)
+	if err != nil {
+		return err
+	}
+	if cr != nil {
+		if errs := cr.Errors(); len(errs) > 0 {
+			if strings.Contains(strings.ToLower(errs[0].Content), "not found") {
+				return fmt.Errorf("%w: %s", ErrMacroNotFound, path)
+			}
+			return fmt.Errorf("Macro %s failed: %s", path, errs[0].Content)
+		}
+	}
+
+	// M98 returns once the macro has been processed but moves may still be pending
+	flushed, err := bcc.FlushCtx(ctx, c.Channel)
+	if err != nil {
+		return err
+	}
+	if !flushed {
+		return ErrFlushAborted
+	}
+	return nil
+}
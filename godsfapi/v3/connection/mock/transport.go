@@ -0,0 +1,193 @@
+/*
+Package mock provides an in-memory connection.ConnectionTransport to test code using the
+connection package without a running DuetControlServer.
+
+Responses are scripted by enqueueing them in the order they will be requested, e.g.
+
+	t := mock.NewTransport()
+	t.EnqueueHandshake(1)
+	t.EnqueueResult("0:/sys/config.g")
+	cc := &connection.CommandConnection{}
+	err := cc.ConnectTransport(initmessages.NewCommandInitMessage(), t)
+	...
+	path, err := cc.ResolvePath("config.g")
+	cmd, err := t.LastCommand()
+*/
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+)
+
+// ErrNothingSent is returned if a sent message is requested but nothing has been sent yet
+var ErrNothingSent = errors.New("Nothing has been sent")
+
+// Transport is a scriptable fake transport. The zero value is not usable, use NewTransport instead.
+type Transport struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	responses [][]byte
+	sent      [][]byte
+	closed    bool
+	sendErr   error
+}
+
+// NewTransport creates a new Transport without any enqueued responses
+func NewTransport() *Transport {
+	t := &Transport{}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// EnqueueHandshake enqueues a compatible ServerInitMessage using the given connection ID
+// followed by a successful response to the client's init message
+func (t *Transport) EnqueueHandshake(id int64) error {
+	err := t.Enqueue(initmessages.ServerInitMessage{Version: initmessages.ProtocolVersion, Id: id})
+	if err != nil {
+		return err
+	}
+	return t.EnqueueResult(nil)
+}
+
+// EnqueueResult enqueues a successful response carrying the given result
+func (t *Transport) EnqueueResult(result interface{}) error {
+	return t.Enqueue(commands.BaseResponse{Success: true, Result: result})
+}
+
+// EnqueueError enqueues an unsuccessful response with the given error details
+func (t *Transport) EnqueueError(errorType, errorMessage string) error {
+	return t.Enqueue(commands.BaseResponse{ErrorType: errorType, ErrorMessage: errorMessage})
+}
+
+// Enqueue serializes v and enqueues it as the next message to be received
+func (t *Transport) Enqueue(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.EnqueueJSON(string(b))
+	return nil
+}
+
+// EnqueueJSON enqueues a raw JSON message to be received. It is not validated so
+// this can also be used to simulate malformed responses.
+func (t *Transport) EnqueueJSON(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.responses = append(t.responses, []byte(s))
+	t.cond.Broadcast()
+}
+
+// FailSend makes all following calls to Send fail with err instead of recording the message.
+// Pass nil to restore normal operation.
+func (t *Transport) FailSend(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sendErr = err
+}
+
+// Pending returns the number of enqueued messages that have not been received yet
+func (t *Transport) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.responses)
+}
+
+// Sent returns a copy of all messages sent so far in the order they were sent
+func (t *Transport) Sent() [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := make([][]byte, len(t.sent))
+	copy(s, t.sent)
+	return s
+}
+
+// SentCommands returns the names of all commands sent so far. The init message
+// sent during the handshake is returned as empty string.
+func (t *Transport) SentCommands() ([]string, error) {
+	sent := t.Sent()
+	names := make([]string, 0, len(sent))
+	for _, b := range sent {
+		var bc commands.BaseCommand
+		if err := json.Unmarshal(b, &bc); err != nil {
+			return nil, err
+		}
+		names = append(names, bc.Command)
+	}
+	return names, nil
+}
+
+// LastCommand returns the name of the most recently sent command
+func (t *Transport) LastCommand() (string, error) {
+	var bc commands.BaseCommand
+	if err := t.LastSent(&bc); err != nil {
+		return "", err
+	}
+	return bc.Command, nil
+}
+
+// LastSent unmarshals the most recently sent message into v
+func (t *Transport) LastSent(v interface{}) error {
+	t.mu.Lock()
+	if len(t.sent) == 0 {
+		t.mu.Unlock()
+		return ErrNothingSent
+	}
+	b := t.sent[len(t.sent)-1]
+	t.mu.Unlock()
+	return json.Unmarshal(b, v)
+}
+
+// Send records the given message
+func (t *Transport) Send(data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return io.ErrClosedPipe
+	}
+	if t.sendErr != nil {
+		return t.sendErr
+	}
+	b := make([]byte, len(data))
+	copy(b, data)
+	t.sent = append(t.sent, b)
+	return nil
+}
+
+// Receive returns the next enqueued message. It blocks until one is available
+// and returns io.EOF once the transport has been closed.
+func (t *Transport) Receive() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for len(t.responses) == 0 && !t.closed {
+		t.cond.Wait()
+	}
+	if t.closed {
+		return nil, io.EOF
+	}
+	b := t.responses[0]
+	t.responses = t.responses[1:]
+	return b, nil
+}
+
+// Close the transport. Pending and future calls to Receive return io.EOF.
+func (t *Transport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	t.cond.Broadcast()
+	return nil
+}
+
+// IsClosed checks if the transport has been closed
+func (t *Transport) IsClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
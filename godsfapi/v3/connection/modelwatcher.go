@@ -0,0 +1,527 @@
+package connection
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/state"
+)
+
+// ModelEvent is implemented by all events emitted by a ModelWatcher
+type ModelEvent interface {
+	modelEvent()
+}
+
+// ModelUpdated is emitted after every update of the model following more specific events
+type ModelUpdated struct {
+	// Model is the updated model
+	Model *machine.SafeModel
+}
+
+// StatusChanged is emitted if the machine status changed
+type StatusChanged struct {
+	Old state.MachineStatus
+	New state.MachineStatus
+}
+
+// HeaterTemperatureChanged is emitted if the current temperature of a heater changed
+type HeaterTemperatureChanged struct {
+	// Index of the heater
+	Index int
+	// Current temperature of the heater (in degC)
+	Current float64
+}
+
+// JobProgressChanged is emitted if the progress of the current job changed
+type JobProgressChanged struct {
+	// Fraction of the file processed on a scale between 0 and 1
+	Fraction float64
+}
+
+// MessageReceived is emitted for every generic message that was added to the messages of the model.
+// Messages that have been delivered before are not repeated even if the model still contains them.
+type MessageReceived struct {
+	Message messages.Message
+}
+
+// Resynced is emitted if Reconnect is enabled and the subscription has been re-established after
+// the connection was lost. The full model has been received again so consumers should refresh
+// everything they derived from it. Events for changes during the outage follow.
+type Resynced struct {
+	// Model is the resynchronized model
+	Model *machine.SafeModel
+}
+
+func (ModelUpdated) modelEvent()             {}
+func (Resynced) modelEvent()                 {}
+func (StatusChanged) modelEvent()            {}
+func (HeaterTemperatureChanged) modelEvent() {}
+func (JobProgressChanged) modelEvent()       {}
+func (MessageReceived) modelEvent()          {}
+
+// modelSnapshot holds the values of the model events are derived from
+type modelSnapshot struct {
+	status   state.MachineStatus
+	heaters  []float64
+	progress float64
+	messages []messages.Message
+}
+
+// newModelSnapshot extracts the values events are derived from
+func newModelSnapshot(mm *machine.MachineModel) modelSnapshot {
+	s := modelSnapshot{
+		status:   mm.State.Status,
+		heaters:  make([]float64, len(mm.Heat.Heaters)),
+		messages: append([]messages.Message(nil), mm.Messages...),
+	}
+	for i, h := range mm.Heat.Heaters {
+		s.heaters[i] = h.Current
+	}
+	if mm.Job.FilePosition != nil && mm.Job.File.Size > 0 {
+		s.progress = float64(*mm.Job.FilePosition) / float64(mm.Job.File.Size)
+	}
+	return s
+}
+
+// events returns the events caused by the transition from the old snapshot to this one
+func (s modelSnapshot) events(old modelSnapshot) []ModelEvent {
+	events := make([]ModelEvent, 0)
+	if s.status != old.status {
+		events = append(events, StatusChanged{Old: old.status, New: s.status})
+	}
+	for i, current := range s.heaters {
+		if i >= len(old.heaters) || old.heaters[i] != current {
+			events = append(events, HeaterTemperatureChanged{Index: i, Current: current})
+		}
+	}
+	if s.progress != old.progress {
+		events = append(events, JobProgressChanged{Fraction: s.progress})
+	}
+	for _, m := range newMessages(old.messages, s.messages) {
+		events = append(events, MessageReceived{Message: m})
+	}
+	return events
+}
+
+// newMessages returns the messages of cur that are not contained in old. The control server
+// clears or truncates the list once messages have been delivered, so the messages cannot be
+// matched by index and are compared by content instead.
+func newMessages(old, cur []messages.Message) []messages.Message {
+	seen := make(map[messages.Message]int, len(old))
+	for _, m := range old {
+		seen[m]++
+	}
+	var result []messages.Message
+	for _, m := range cur {
+		if seen[m] > 0 {
+			seen[m]--
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// modelSubscriber is a single consumer of a ModelWatcher
+type modelSubscriber struct {
+	events chan ModelEvent
+	done   chan struct{}
+	// mu is held while sending to events so it is not closed during a send
+	mu     sync.Mutex
+	closed bool
+}
+
+// send delivers an event unless the subscriber has been removed in the meantime
+func (s *modelSubscriber) send(e ModelEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- e:
+	case <-s.done:
+	}
+}
+
+// close closes the events channel once no send is in progress
+func (s *modelSubscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
+
+// messageSubscriber is a consumer of the channel returned by ModelWatcher.Messages
+type messageSubscriber struct {
+	events <-chan ModelEvent
+	done   chan struct{}
+}
+
+// ModelWatcher runs the receive loop of a SubscribeConnection and turns the updates
+// into typed events that are fanned out to all subscribers.
+//
+// Events are delivered in order and the watcher waits for every subscriber to accept them.
+// A slow subscriber therefore delays acknowledging updates which makes the control server
+// merge the following changes into fewer updates. Use a buffered subscription to decouple.
+type ModelWatcher struct {
+	// CoalesceWindow makes the watcher keep receiving and acknowledging updates in the background
+	// and deliver the merged result at most once per window instead of every update. Intermediate
+	// values are skipped (e.g. a short status change) but the final state is always delivered once
+	// updates stop. Zero disables coalescing. It must be set before calling Run.
+	CoalesceWindow time.Duration
+	// Reconnect makes the watcher re-establish the subscription with the same settings if the
+	// connection is lost, e.g. because DCS was restarted. Once resubscribed the full model is
+	// received and Resynced is emitted. It must be set before calling Run.
+	Reconnect bool
+	// MaxRetries limits the number of attempts to reconnect per outage. Zero means unlimited.
+	MaxRetries int
+	// InitialBackoff is the delay before the first attempt to reconnect. It is doubled
+	// after each failed attempt. Defaults to DefaultInitialBackoff if not set.
+	InitialBackoff time.Duration
+	// MaxBackoff is the upper limit of the delay between two attempts to reconnect.
+	// Defaults to DefaultMaxBackoff if not set.
+	MaxBackoff time.Duration
+	// KeepRaw makes the watcher keep the undecoded JSON of the model in parallel to the typed model
+	// (see Raw). This costs an additional merge of every update. It must be set before calling Run.
+	KeepRaw bool
+	sc      *SubscribeConnection
+	model   *machine.SafeModel
+	// mu guards the following fields
+	mu          sync.Mutex
+	raw         json.RawMessage
+	subscribers map[<-chan ModelEvent]*modelSubscriber
+	messages    map[<-chan messages.Message]*messageSubscriber
+	stopped     bool
+	closed      bool
+	resynced    bool
+}
+
+// NewModelWatcher creates a new ModelWatcher for an established subscription
+// in either Full or Patch mode
+func NewModelWatcher(sc *SubscribeConnection) *ModelWatcher {
+	return &ModelWatcher{
+		sc:          sc,
+		model:       machine.NewSafeModel(nil),
+		subscribers: make(map[<-chan ModelEvent]*modelSubscriber),
+		messages:    make(map[<-chan messages.Message]*messageSubscriber),
+	}
+}
+
+// NewSharedModelWatcher creates a new ModelWatcher like NewModelWatcher that keeps the given
+// shared model up-to-date instead of a model of its own, e.g. to share it with a CommandConnection
+// via CommandConnection.AttachSharedModel
+func NewSharedModelWatcher(sc *SubscribeConnection, sm *machine.SharedModel) *ModelWatcher {
+	w := NewModelWatcher(sc)
+	w.model = &sm.SafeModel
+	return w
+}
+
+// Model returns the model that is kept up-to-date by this watcher
+func (w *ModelWatcher) Model() *machine.SafeModel {
+	return w.model
+}
+
+// Raw returns the undecoded JSON of the model if KeepRaw is set or nil otherwise.
+// The raw model is authoritative: it contains fields added by newer firmware versions that are
+// not modelled by MachineModel yet. It is updated right after Model whenever an update was received.
+// The returned slice must not be modified.
+func (w *ModelWatcher) Raw() json.RawMessage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.raw
+}
+
+// Subscribe returns a new channel receiving all following events. The channel is
+// closed once Run has returned or Unsubscribe is called.
+func (w *ModelWatcher) Subscribe(buffer int) <-chan ModelEvent {
+	s := &modelSubscriber{events: make(chan ModelEvent, buffer), done: make(chan struct{})}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		s.close()
+	} else {
+		w.subscribers[s.events] = s
+	}
+	return s.events
+}
+
+// Messages returns a channel receiving every new generic message of the model (see MessageReceived).
+// The channel is closed once Run has returned or UnsubscribeMessages is called. It has to be drained
+// until then since the watcher waits for every message to be accepted.
+func (w *ModelWatcher) Messages(buffer int) <-chan messages.Message {
+	ms := &messageSubscriber{events: w.Subscribe(buffer), done: make(chan struct{})}
+	result := make(chan messages.Message, buffer)
+	w.mu.Lock()
+	w.messages[result] = ms
+	w.mu.Unlock()
+	go func() {
+		defer func() {
+			w.mu.Lock()
+			delete(w.messages, result)
+			w.mu.Unlock()
+			close(result)
+		}()
+		for e := range ms.events {
+			if mr, ok := e.(MessageReceived); ok {
+				select {
+				case result <- mr.Message:
+				case <-ms.done:
+				}
+			}
+		}
+	}()
+	return result
+}
+
+// Unsubscribe stops delivering events to the given channel and closes it.
+// Events that have not been received yet are discarded.
+func (w *ModelWatcher) Unsubscribe(events <-chan ModelEvent) {
+	w.mu.Lock()
+	s, ok := w.subscribers[events]
+	delete(w.subscribers, events)
+	w.mu.Unlock()
+	if ok {
+		// Wake up a pending send before closing the channel
+		close(s.done)
+		s.close()
+	}
+}
+
+// UnsubscribeMessages stops delivering messages to a channel returned by Messages and closes it.
+// Messages that have not been received yet are discarded.
+func (w *ModelWatcher) UnsubscribeMessages(msgs <-chan messages.Message) {
+	w.mu.Lock()
+	ms, ok := w.messages[msgs]
+	delete(w.messages, msgs)
+	w.mu.Unlock()
+	if ok {
+		close(ms.done)
+		w.Unsubscribe(ms.events)
+	}
+}
+
+// Run receives the full model followed by all updates until the connection fails and cannot be
+// re-established (see Reconnect) or Close is called.
+// The error is returned and all subscriber channels are closed afterwards.
+func (w *ModelWatcher) Run() error {
+	defer w.stop()
+
+	if err := w.receiveModel(); err != nil {
+		return err
+	}
+	var prev modelSnapshot
+	w.model.With(func(mm *machine.MachineModel) { prev = newModelSnapshot(mm) })
+	w.emit(ModelUpdated{Model: w.model})
+
+	if w.CoalesceWindow > 0 {
+		return w.runCoalesced(prev)
+	}
+	for {
+		if err := w.next(); err != nil {
+			return err
+		}
+		prev = w.deliver(prev)
+	}
+}
+
+// runCoalesced receives updates in a separate goroutine and delivers them once per CoalesceWindow
+func (w *ModelWatcher) runCoalesced(prev modelSnapshot) error {
+	updates := make(chan struct{}, 1)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			if err := w.next(); err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case updates <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	var tick <-chan time.Time
+	for {
+		select {
+		case <-updates:
+			if tick == nil {
+				tick = time.After(w.CoalesceWindow)
+			}
+		case <-tick:
+			tick = nil
+			prev = w.deliver(prev)
+		case err := <-errc:
+			if tick != nil || len(updates) > 0 {
+				w.deliver(prev)
+			}
+			return err
+		}
+	}
+}
+
+// Close stops the watcher and closes its connection. Run returns afterwards.
+// Use this instead of closing the connection to stop a watcher that reconnects.
+func (w *ModelWatcher) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	return w.sc.Close()
+}
+
+// isClosed checks if Close has been called
+func (w *ModelWatcher) isClosed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+// next receives the next update and re-establishes the subscription if the connection was lost
+func (w *ModelWatcher) next() error {
+	err := w.receive()
+	if err == nil || !w.Reconnect || !isTransportError(err) || w.sc.isClosing() || w.isClosed() {
+		return err
+	}
+	return w.resync(err)
+}
+
+// resync re-establishes the subscription using exponential backoff and receives the full model again.
+// The last error is returned if all attempts failed.
+func (w *ModelWatcher) resync(err error) error {
+	w.sc.logger().Warnf("<Watch> Subscription lost, reconnecting: %v", err)
+	backoff := w.InitialBackoff
+	if backoff <= 0 {
+		backoff = DefaultInitialBackoff
+	}
+	maxBackoff := w.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	for attempt := 1; w.MaxRetries <= 0 || attempt <= w.MaxRetries; attempt++ {
+		time.Sleep(backoff)
+		if w.isClosed() {
+			return err
+		}
+
+		if err = w.sc.Reconnect(); err == nil {
+			if err = w.receiveModel(); err == nil {
+				w.mu.Lock()
+				w.resynced = true
+				w.mu.Unlock()
+				w.sc.logger().Infof("<Watch> Subscription re-established")
+				return nil
+			}
+		}
+		w.sc.logger().Warnf("<Watch> Attempt %d failed: %v", attempt, err)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	w.sc.logger().Errorf("<Watch> Giving up after %d attempts", w.MaxRetries)
+	return err
+}
+
+// receive the next update and merge it into the model
+func (w *ModelWatcher) receive() error {
+	if w.sc.Mode == initmessages.SubscriptionModeFull {
+		return w.receiveModel()
+	}
+	if !w.KeepRaw {
+		return w.sc.ApplyMachineModelPatch(w.model)
+	}
+	patch, err := w.sc.GetMachineModelPatch()
+	if err != nil {
+		return err
+	}
+	if err = w.model.ApplyPatch([]byte(patch)); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	raw, err := machine.MergeRawPatch(w.raw, json.RawMessage(patch))
+	if err != nil {
+		return err
+	}
+	w.raw = raw
+	return nil
+}
+
+// receiveModel receives the full model and replaces the current one
+func (w *ModelWatcher) receiveModel() error {
+	if !w.KeepRaw {
+		mm, err := w.sc.GetMachineModel()
+		if err != nil {
+			return err
+		}
+		w.model.Set(mm)
+		return nil
+	}
+	raw, err := w.sc.GetMachineModelPatch()
+	if err != nil {
+		return err
+	}
+	mm := machine.NewMachineModel()
+	if err = json.Unmarshal([]byte(raw), mm); err != nil {
+		return &DecodeError{Target: fmt.Sprintf("%T", mm), Err: err}
+	}
+	w.model.Set(mm)
+	w.mu.Lock()
+	w.raw = json.RawMessage(raw)
+	w.mu.Unlock()
+	return nil
+}
+
+// deliver the events caused by the changes since the given snapshot and return the current one
+func (w *ModelWatcher) deliver(prev modelSnapshot) modelSnapshot {
+	w.mu.Lock()
+	resynced := w.resynced
+	w.resynced = false
+	w.mu.Unlock()
+	if resynced {
+		w.emit(Resynced{Model: w.model})
+	}
+
+	var cur modelSnapshot
+	w.model.With(func(mm *machine.MachineModel) { cur = newModelSnapshot(mm) })
+	for _, e := range cur.events(prev) {
+		w.emit(e)
+	}
+	w.emit(ModelUpdated{Model: w.model})
+	return cur
+}
+
+// emit delivers an event to all current subscribers
+func (w *ModelWatcher) emit(e ModelEvent) {
+	w.mu.Lock()
+	subscribers := make([]*modelSubscriber, 0, len(w.subscribers))
+	for _, s := range w.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	w.mu.Unlock()
+
+	for _, s := range subscribers {
+		s.send(e)
+	}
+}
+
+// stop closes all subscriber channels
+func (w *ModelWatcher) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	for events, s := range w.subscribers {
+		s.close()
+		delete(w.subscribers, events)
+	}
+}
@@ -0,0 +1,90 @@
+package connection
+
+import (
+	"context"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+)
+
+// ResolvePaths resolves multiple RepRapFirmware-style file paths to real file paths at once.
+// All requests are sent without waiting for the previous ones so this only takes a single
+// round-trip. The resolved paths are returned at the same indices. If CachePaths is set
+// only paths not resolved before are sent to the control server.
+func (bcc *BaseCommandConnection) ResolvePaths(paths []string) ([]string, error) {
+	resolved := make([]string, len(paths))
+	errs := make([]error, len(paths))
+
+	// Receive responses concurrently so the control server is never blocked writing them
+	type pending struct {
+		index   int
+		r       *request
+		command *commands.ResolvePath
+	}
+	pc := make(chan pending, len(paths))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range pc {
+			_, errs[p.index] = bcc.awaitCommand(context.Background(), p.r, p.command, &resolved[p.index])
+		}
+	}()
+
+	var sendErr error
+	for i, path := range paths {
+		if cached, ok := bcc.cachedPath(path); ok {
+			resolved[i] = cached
+			continue
+		}
+		rp := commands.NewResolvePath(path)
+		r, err := bcc.sendRequest(rp)
+		if err != nil {
+			sendErr = err
+			break
+		}
+		pc <- pending{index: i, r: r, command: rp}
+	}
+	close(pc)
+	<-done
+
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	for i, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+		bcc.cachePath(paths[i], resolved[i])
+	}
+	return resolved, nil
+}
+
+// InvalidatePathCache discards all cached path resolutions
+func (bcc *BaseCommandConnection) InvalidatePathCache() {
+	bcc.pathCacheMu.Lock()
+	defer bcc.pathCacheMu.Unlock()
+	bcc.pathCache = nil
+}
+
+// cachedPath looks up a previous resolution of path if caching is enabled
+func (bcc *BaseCommandConnection) cachedPath(path string) (string, bool) {
+	if !bcc.CachePaths {
+		return "", false
+	}
+	bcc.pathCacheMu.Lock()
+	defer bcc.pathCacheMu.Unlock()
+	resolved, ok := bcc.pathCache[path]
+	return resolved, ok
+}
+
+// cachePath remembers the resolution of path if caching is enabled
+func (bcc *BaseCommandConnection) cachePath(path, resolved string) {
+	if !bcc.CachePaths {
+		return
+	}
+	bcc.pathCacheMu.Lock()
+	defer bcc.pathCacheMu.Unlock()
+	if bcc.pathCache == nil {
+		bcc.pathCache = make(map[string]string)
+	}
+	bcc.pathCache[path] = resolved
+}
@@ -0,0 +1,56 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// RunPeriodically executes code on the Daemon channel once per interval until ctx is done and returns
+// ctx.Err() then. The first execution starts immediately and executions never overlap, so a code
+// taking longer than interval delays the next one.
+//
+// Codes of the Daemon channel are processed independently from all other channels, so they neither wait
+// for nor interleave with codes of a running job or of users on the HTTP or File channels. They still
+// share the move queue though, so moves should be avoided.
+//
+// onError is called for every execution that fails, either because the connection failed (which stops
+// the loop) or because the code produced an error message. If it returns an error the loop stops and
+// that error is returned. onError may be nil in which case the first error stops the loop.
+func (bcc *BaseCommandConnection) RunPeriodically(ctx context.Context, interval time.Duration, code string, onError func(err error) error) error {
+	if interval <= 0 {
+		return errors.New("Interval must be positive")
+	}
+	c, err := commands.ParseCode(code)
+	if err != nil {
+		return err
+	}
+	c.Channel = types.Daemon
+	if onError == nil {
+		onError = func(err error) error { return err }
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err = bcc.performCodeChecked(ctx, c); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if herr := onError(err); herr != nil {
+				return herr
+			}
+			if isTransportError(err) {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/plugins"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// ErrPermissionDenied is returned (wrapped) by helpers that require a permission
+// this connection has not been granted
+var ErrPermissionDenied = errors.New("Permission denied")
+
+// impliedPermissions lists the permissions that are included in another one
+var impliedPermissions = map[types.SbcPermissions][]types.SbcPermissions{
+	types.CodeInterceptionReadWrite: {types.CodeInterceptionRead},
+	types.ObjectModelReadWrite:      {types.ObjectModelRead},
+	types.WriteFilaments:            {types.ReadFilaments},
+	types.WriteFirmware:             {types.ReadFirmware},
+	types.WriteGCodes:               {types.ReadGCodes},
+	types.WriteMacros:               {types.ReadMacros},
+	types.WriteMenu:                 {types.ReadMenu},
+	types.WriteSystem:               {types.ReadSystem},
+	types.WriteWeb:                  {types.ReadWeb},
+}
+
+// Permissions returns the permissions granted to this connection.
+// They are taken from the handshake if the control server reported them. Otherwise the control
+// server grants the permissions of the plugin whose SBC executable owns this process, so these
+// are looked up in the object model. Processes that are not running as a plugin are not restricted
+// and all permissions are returned for them. The result is cached after the first successful call.
+func (bcc *BaseCommandConnection) Permissions() ([]types.SbcPermissions, error) {
+	granted, err := bcc.permissionSet()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]types.SbcPermissions, 0, len(granted))
+	for _, p := range types.AllSbcPermissions() {
+		if granted[p] {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// Can checks if this connection has been granted the given permission, either directly or
+// implicitly (e.g. ObjectModelReadWrite includes ObjectModelRead).
+// False is returned if the permissions could not be determined.
+func (bcc *BaseCommandConnection) Can(permission types.SbcPermissions) bool {
+	granted, err := bcc.permissionSet()
+	if err != nil {
+		bcc.logger().Warnf("<Permissions> Failed to determine permissions: %v", err)
+		return false
+	}
+	return granted[permission]
+}
+
+// permissionSet returns the cached set of granted permissions including the implied ones
+func (bcc *BaseCommandConnection) permissionSet() (map[types.SbcPermissions]bool, error) {
+	bcc.permissionsMu.Lock()
+	defer bcc.permissionsMu.Unlock()
+	if bcc.grantedPermissions != nil {
+		return bcc.grantedPermissions, nil
+	}
+
+	permissions := bcc.permissions
+	if permissions == nil {
+		_, p, err := bcc.ownPlugin(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		permissions = types.AllSbcPermissions()
+		if p != nil {
+			permissions = p.SbcPermissions
+		}
+	}
+
+	granted := make(map[types.SbcPermissions]bool)
+	for _, p := range permissions {
+		granted[p] = true
+		for _, ip := range impliedPermissions[p] {
+			granted[ip] = true
+		}
+	}
+	delete(granted, types.None)
+	bcc.grantedPermissions = granted
+	return granted, nil
+}
+
+// ownPlugin looks up the plugin whose SBC executable owns this process.
+// A nil plugin is returned if this process is not running as a plugin.
+func (bcc *BaseCommandConnection) ownPlugin(ctx context.Context) (string, *plugins.Plugin, error) {
+	var ps map[string]plugins.Plugin
+	if err := bcc.GetObjectModelKeyCtx(ctx, "plugins", &ps); err != nil {
+		return "", nil, err
+	}
+	for name, p := range ps {
+		if p.Pid == int64(os.Getpid()) {
+			return name, &p, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// CanManagePlugin checks if this connection may modify the given plugin, e.g. set its data
+// using SetPluginData. This is always possible for the own plugin and requires the
+// ManagePlugins permission for all others.
+// False is returned if the permissions could not be determined.
+func (bcc *BaseCommandConnection) CanManagePlugin(name string) bool {
+	if bcc.Can(types.ManagePlugins) {
+		return true
+	}
+	own, p, err := bcc.ownPlugin(context.Background())
+	if err != nil {
+		bcc.logger().Warnf("<Permissions> Failed to determine own plugin: %v", err)
+		return false
+	}
+	return p != nil && (name == "" || name == own)
+}
+
+// permissionError wraps a CommandError caused by missing permissions so it matches ErrPermissionDenied
+func permissionError(err error) error {
+	var ce *CommandError
+	if errors.As(err, &ce) && ce.Type == UnauthorizedAccessException {
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, ce.Reason)
+	}
+	return err
+}
+
+// requirePermission returns an error wrapping ErrPermissionDenied unless the given permission has been granted
+func (bcc *BaseCommandConnection) requirePermission(permission types.SbcPermissions) error {
+	granted, err := bcc.permissionSet()
+	if err != nil {
+		return err
+	}
+	if !granted[permission] {
+		return fmt.Errorf("%w: %s is required", ErrPermissionDenied, permission)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/inputs"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// GetChannelState returns the state of the given code channel from the object model, e.g. to find out
+// if the Queue channel is still busy. The object model does not list the individual codes waiting on
+// a channel, use QueuedCodes to get the codes queued by this connection.
+func (bcc *BaseCommandConnection) GetChannelState(ctx context.Context, channel types.CodeChannel) (*inputs.InputChannel, error) {
+	var ics []*inputs.InputChannel
+	if err := bcc.GetObjectModelKeyCtx(ctx, "inputs", &ics); err != nil {
+		return nil, err
+	}
+	for _, ic := range ics {
+		if ic != nil && ic.Name == channel {
+			return ic, nil
+		}
+	}
+	return nil, fmt.Errorf("Code channel %s does not exist", channel)
+}
+
+// QueuedCodes returns copies of the codes sent to the Queue channel by this connection that have not
+// finished yet in the order they were sent. The control server does not expose the codes of a channel
+// so codes queued by other connections are not included.
+func (bcc *BaseCommandConnection) QueuedCodes() []*commands.Code {
+	bcc.queueMu.Lock()
+	defer bcc.queueMu.Unlock()
+	codes := make([]*commands.Code, len(bcc.queued))
+	for i, c := range bcc.queued {
+		codes[i] = c.Clone()
+	}
+	return codes
+}
+
+// ClearQueue aborts all codes that are pending on the Queue channel including the one being executed
+// and returns copies of the aborted codes sent by this connection (see QueuedCodes) so they can be
+// requeued, e.g. via PerformCodeAsync. Codes that finish while the queue is being cleared may be
+// included as well.
+//
+// PerformCode calls still waiting for any of these codes fail with an error matching ErrCodeCancelled.
+// Codes that have already finished are not affected. The channel is invalidated over a separate connection
+// so this works even while this connection is waiting for a queued code. Connections established via
+// ConnectTransport return ErrNoSeparateConnection (see CancelPendingCode).
+func (bcc *BaseCommandConnection) ClearQueue() ([]*commands.Code, error) {
+	codes := bcc.QueuedCodes()
+	if err := bcc.invalidateChannelSeparately(types.Queue); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// sendCode sends a code like sendRequest and tracks it until its response has been received.
+// Codes whose caller stopped waiting remain tracked since the control server still has them.
+func (bcc *BaseCommandConnection) sendCode(code *commands.Code) (*request, error) {
+	untrack := bcc.trackQueued(code)
+	r, err := bcc.sendRequest(code)
+	if err != nil {
+		untrack()
+		return nil, err
+	}
+	r.received = untrack
+	return r, nil
+}
+
+// trackQueued remembers a code sent to the Queue channel until the returned function is called
+func (bcc *BaseCommandConnection) trackQueued(code *commands.Code) func() {
+	if code.Channel != types.Queue {
+		return func() {}
+	}
+	bcc.queueMu.Lock()
+	bcc.queued = append(bcc.queued, code)
+	bcc.queueMu.Unlock()
+	return func() {
+		bcc.queueMu.Lock()
+		defer bcc.queueMu.Unlock()
+		for i, c := range bcc.queued {
+			if c == code {
+				bcc.queued = append(bcc.queued[:i], bcc.queued[i+1:]...)
+				return
+			}
+		}
+	}
+}
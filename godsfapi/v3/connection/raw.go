@@ -0,0 +1,51 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// rawCommand is an arbitrary command that was serialized in advance
+type rawCommand struct {
+	name string
+	data json.RawMessage
+}
+
+func (c *rawCommand) GetCommand() string { return c.name }
+
+func (c *rawCommand) MarshalJSON() ([]byte, error) { return c.data, nil }
+
+// DoRaw performs an arbitrary command that is not wrapped by this library yet and decodes the result
+// of the response into result unless it is nil.
+//
+// This is an escape hatch for advanced users to use commands of newer control servers without waiting for
+// support in this library. command may be any value that marshals to a JSON object with a "command" field
+// naming the command, e.g. a custom struct, a map or a json.RawMessage. The command is sent and its response
+// received like every other command so responses of concurrent commands cannot get mixed up and all errors
+// are reported as described by Error. Prefer the typed methods whenever possible.
+func (bc *BaseConnection) DoRaw(ctx context.Context, command interface{}, result interface{}) error {
+	var data []byte
+	switch c := command.(type) {
+	case json.RawMessage:
+		data = c
+	case []byte:
+		data = c
+	default:
+		var err error
+		if data, err = json.Marshal(command); err != nil {
+			return err
+		}
+	}
+	var header struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+	if header.Command == "" {
+		return errors.New("Raw command has no command field")
+	}
+	_, err := bc.performCommand(ctx, &rawCommand{name: header.Command, data: data}, result)
+	return err
+}
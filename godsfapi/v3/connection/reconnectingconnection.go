@@ -0,0 +1,180 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInitialBackoff is the default delay before the first attempt to reconnect
+	DefaultInitialBackoff = 100 * time.Millisecond
+	// DefaultMaxBackoff is the default upper limit of the delay between two attempts to reconnect
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// ErrReconnecting is returned (wrapped) by calls that failed because the connection
+// to the control server was lost and is being re-established
+var ErrReconnecting = errors.New("Connection to control server lost, reconnecting")
+
+// ReconnectingConnection is a CommandConnection that re-establishes the connection to
+// the control server once the socket breaks, e.g. because DCS was restarted.
+// Only the transport is restored: commands that were pending or issued during the outage
+// are not re-sent but fail with an error wrapping ErrReconnecting instead so callers
+// can decide whether to retry them.
+type ReconnectingConnection struct {
+	CommandConnection
+	// MaxRetries limits the number of attempts to reconnect per outage. Zero means unlimited.
+	// If all attempts failed the next failing call will start over again.
+	MaxRetries int
+	// InitialBackoff is the delay before the first attempt to reconnect. It is doubled
+	// after each failed attempt. Defaults to DefaultInitialBackoff if not set.
+	InitialBackoff time.Duration
+	// MaxBackoff is the upper limit of the delay between two attempts to reconnect.
+	// Defaults to DefaultMaxBackoff if not set.
+	MaxBackoff time.Duration
+	// OnReconnect is called after the connection has been re-established
+	OnReconnect func()
+
+	// rmu guards the following fields
+	rmu          sync.Mutex
+	reconnecting bool
+	closed       bool
+}
+
+// Connect sends a CommandInitMessage to the server and enables reconnecting
+func (rc *ReconnectingConnection) Connect(socketPath string) error {
+	err := rc.CommandConnection.Connect(socketPath)
+	if err != nil {
+		return err
+	}
+	rc.rmu.Lock()
+	rc.closed = false
+	rc.rmu.Unlock()
+	rc.onTransportError = rc.handleTransportError
+	return nil
+}
+
+// Close the UNIX socket connection and stop reconnecting
+func (rc *ReconnectingConnection) Close() error {
+	if rc == nil {
+		return nil
+	}
+	rc.rmu.Lock()
+	rc.closed = true
+	rc.rmu.Unlock()
+	return rc.CommandConnection.Close()
+}
+
+// CloseNow closes the UNIX socket connection without waiting for pending commands and stops reconnecting
+func (rc *ReconnectingConnection) CloseNow() error {
+	if rc == nil {
+		return nil
+	}
+	rc.rmu.Lock()
+	rc.closed = true
+	rc.rmu.Unlock()
+	return rc.CommandConnection.CloseNow()
+}
+
+// handleTransportError starts reconnecting in the background if the error occurred
+// on the current socket and wraps err with ErrReconnecting
+func (rc *ReconnectingConnection) handleTransportError(transport ConnectionTransport, err error) error {
+	rc.rmu.Lock()
+	defer rc.rmu.Unlock()
+	if rc.closed {
+		return err
+	}
+	if !rc.reconnecting && rc.isLive(transport) {
+		rc.reconnecting = true
+		go rc.reconnect()
+	}
+	return &TransportError{Err: fmt.Errorf("%w: %v", ErrReconnecting, errors.Unwrap(err))}
+}
+
+// reconnect tries to re-establish the connection using exponential backoff
+func (rc *ReconnectingConnection) reconnect() {
+	// Make sure all responses still pending on the broken socket fail immediately
+	rc.mu.Lock()
+	if rc.transport != nil {
+		rc.transport.Close()
+	}
+	rc.mu.Unlock()
+
+	backoff := rc.InitialBackoff
+	if backoff <= 0 {
+		backoff = DefaultInitialBackoff
+	}
+	maxBackoff := rc.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	for attempt := 1; rc.MaxRetries <= 0 || attempt <= rc.MaxRetries; attempt++ {
+		time.Sleep(backoff)
+
+		rc.rmu.Lock()
+		closed := rc.closed
+		rc.rmu.Unlock()
+		if closed {
+			return
+		}
+
+		nc := &BaseConnection{
+			Debug:        rc.Debug,
+			Logger:       rc.Logger,
+			Dialer:       rc.Dialer,
+			DialTimeout:  rc.DialTimeout,
+			ReadTimeout:  rc.ReadTimeout,
+			WriteTimeout: rc.WriteTimeout,
+			Tap:          rc.Tap,
+		}
+		err := nc.connect(rc.initMessage, rc.network, rc.address)
+		if err == nil {
+			if rc.swap(nc) && rc.OnReconnect != nil {
+				rc.OnReconnect()
+			}
+			return
+		}
+		nc.Close()
+		rc.logger().Warnf("<Reconnect> Attempt %d failed: %v", attempt, err)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	rc.logger().Errorf("<Reconnect> Giving up after %d attempts", rc.MaxRetries)
+	rc.rmu.Lock()
+	rc.reconnecting = false
+	rc.rmu.Unlock()
+}
+
+// swap replaces the broken transport by the one of the given connection.
+// It returns false if this connection has been closed in the meantime.
+func (rc *ReconnectingConnection) swap(nc *BaseConnection) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.rmu.Lock()
+	defer rc.rmu.Unlock()
+
+	rc.reconnecting = false
+	if rc.closed {
+		nc.Close()
+		return false
+	}
+	rc.transport = nc.transport
+	rc.id = nc.id
+	rc.serverVersion = nc.serverVersion
+	rc.permissions = nc.permissions
+	rc.lastRequest = nil
+	rc.resetState(nc.transport)
+	rc.updateStats(func(s *connectionStats) {
+		s.id, s.serverVersion = nc.id, nc.serverVersion
+		s.reconnects++
+	})
+	rc.logger().Infof("<Reconnect> Connection re-established")
+	return true
+}
@@ -0,0 +1,105 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine"
+)
+
+// DefaultRetryOptions are reasonable options for polling read-only data from a ReconnectingConnection
+var DefaultRetryOptions = RetryOptions{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, Jitter: 0.2}
+
+// idempotentCommands are the commands that may be repeated without side effects
+var idempotentCommands = map[string]bool{
+	"CheckPassword":      true,
+	"GetFileInfo":        true,
+	"GetObjectModel":     true,
+	"ResolvePath":        true,
+	"EvaluateExpression": true,
+}
+
+// RetryOptions control how often and how fast failed commands are retried
+type RetryOptions struct {
+	// MaxAttempts is the total number of attempts including the first one
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It is doubled for each further retry.
+	BaseDelay time.Duration
+	// Jitter randomizes each delay by up to this fraction (0-1) in either direction
+	Jitter float64
+}
+
+// delay returns the delay before the given retry (starting at 1)
+func (ro RetryOptions) delay(retry int) time.Duration {
+	d := ro.BaseDelay << uint(retry-1)
+	if ro.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * ro.Jitter * float64(d))
+	}
+	return d
+}
+
+// IsRetryable checks if the error was caused by the connection rather than the command itself.
+// Retrying only succeeds if the connection is re-established in the meantime, e.g. by a
+// ReconnectingConnection.
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrReconnecting) || isTransportError(err)
+}
+
+// Retry calls fn until it succeeds, returns an error that is not retryable (see IsRetryable),
+// opts.MaxAttempts is reached or ctx is done. The last error is returned.
+// fn must be idempotent, i.e. only read data; never use this to perform codes since a code
+// whose response got lost may have been executed already.
+func Retry(ctx context.Context, opts RetryOptions, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil || !IsRetryable(err) || attempt >= opts.MaxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Giving up after %d attempts: %w (last error: %v)", attempt, ctx.Err(), err)
+		case <-time.After(opts.delay(attempt)):
+		}
+	}
+}
+
+// PerformCommandRetry performs an idempotent command and retries it as described by Retry.
+// Commands that may have side effects such as codes are rejected.
+func (bcc *BaseCommandConnection) PerformCommandRetry(ctx context.Context, opts RetryOptions, command commands.Command) (commands.Response, error) {
+	if !idempotentCommands[command.GetCommand()] {
+		return nil, fmt.Errorf("Command %s is not idempotent and cannot be retried", command.GetCommand())
+	}
+	var resp commands.Response
+	err := Retry(ctx, opts, func(ctx context.Context) error {
+		var err error
+		resp, err = bcc.PerformCommandCtx(ctx, command)
+		return err
+	})
+	return resp, err
+}
+
+// GetObjectModelRetry retrieves the full object model and retries as described by Retry
+func (bcc *BaseCommandConnection) GetObjectModelRetry(ctx context.Context, opts RetryOptions) (*machine.MachineModel, error) {
+	var mm *machine.MachineModel
+	err := Retry(ctx, opts, func(ctx context.Context) error {
+		var err error
+		mm, err = bcc.GetObjectModelCtx(ctx)
+		return err
+	})
+	return mm, err
+}
+
+// ResolvePathRetry resolves a RepRapFirmware-style path and retries as described by Retry
+func (bcc *BaseCommandConnection) ResolvePathRetry(ctx context.Context, opts RetryOptions, path string) (string, error) {
+	var resolved string
+	err := Retry(ctx, opts, func(ctx context.Context) error {
+		var err error
+		resolved, err = bcc.ResolvePathCtx(ctx, path)
+		return err
+	})
+	return resolved, err
+}
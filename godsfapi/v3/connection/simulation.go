@@ -0,0 +1,62 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/job"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/state"
+)
+
+// ErrNoSimulatedTime is returned by SimulatePrint if the simulation did not yield a processing time,
+// e.g. because it was cancelled
+var ErrNoSimulatedTime = errors.New("File has no simulated time")
+
+// SimulationResult is the outcome of simulating a file
+type SimulationResult struct {
+	// FileName of the simulated file
+	FileName string
+	// SimulatedTime is the processing time determined by the firmware
+	SimulatedTime time.Duration
+	// Layers is the number of layers of the file or 0 if unknown
+	Layers int64
+	// Info is the updated file information including the slicer estimate for comparison
+	Info *job.ParsedFileInfo
+}
+
+// SimulatePrint simulates a file using M37 to determine its actual processing time and waits for the
+// simulation to finish. This can take a long time for big files so pass a ctx that is long enough.
+// If ctx is done first the simulation keeps running; use CancelJob to stop it.
+func (bcc *BaseCommandConnection) SimulatePrint(ctx context.Context, fileName string) (*SimulationResult, error) {
+	if err := bcc.performJobCode(ctx, fmt.Sprintf("M37 P\"%s\"", strings.ReplaceAll(fileName, "\"", "\"\""))); err != nil {
+		return nil, err
+	}
+
+	// The status may lag behind M37 and small files may be simulated before it changes at all
+	startCtx, cancel := context.WithTimeout(ctx, modelConfirmTimeout)
+	err := bcc.awaitStatus(startCtx, func(s state.MachineStatus) bool { return s == state.Simulating })
+	cancel()
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	if err = bcc.awaitStatus(ctx, func(s state.MachineStatus) bool { return s != state.Simulating }); err != nil {
+		return nil, err
+	}
+
+	info, err := bcc.GetFileInfoCtx(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if info.SimulatedTime == nil {
+		return nil, ErrNoSimulatedTime
+	}
+	return &SimulationResult{
+		FileName:      fileName,
+		SimulatedTime: info.SimulatedTime.Duration(),
+		Layers:        info.NumLayers,
+		Info:          info,
+	}, nil
+}
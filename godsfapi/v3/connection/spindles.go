@@ -0,0 +1,108 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/spindles"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/state"
+)
+
+const (
+	// spindleRpmTolerance is the maximum difference between a requested and a reported spindle RPM
+	spindleRpmTolerance = 0.5
+	// laserPwmScale is the S value of M3 for full laser power unless configured otherwise using M452 R
+	laserPwmScale = 255
+	// laserPwmTolerance is the maximum difference between a requested and a reported laser PWM
+	laserPwmTolerance = 0.005
+)
+
+// SetSpindleRPM starts the given spindle (M3 or M4 for negative values which turn it counter-clockwise)
+// and waits until the object model reports the new RPM. An error is returned if the spindle does
+// not exist or if rpm exceeds its configured maximum.
+func (bcc *BaseCommandConnection) SetSpindleRPM(index int, rpm float64) error {
+	if math.IsNaN(rpm) || math.IsInf(rpm, 0) {
+		return fmt.Errorf("Invalid spindle RPM %v", rpm)
+	}
+	ctx := context.Background()
+	s, err := bcc.spindle(ctx, index)
+	if err != nil {
+		return err
+	}
+	if s.Max > 0 && math.Abs(rpm) > s.Max {
+		return fmt.Errorf("Spindle RPM %v exceeds the maximum of spindle %d (%v)", rpm, index, s.Max)
+	}
+	if rpm == 0 {
+		return bcc.StopSpindle(index)
+	}
+	code := "M3"
+	if rpm < 0 {
+		code = "M4"
+	}
+	code = fmt.Sprintf("%s P%d S%s", code, index, strconv.FormatFloat(math.Abs(rpm), 'f', -1, 64))
+	return bcc.setSpindle(ctx, index, code, math.Abs(rpm))
+}
+
+// StopSpindle turns the given spindle off (M5) and waits until the object model reports it
+func (bcc *BaseCommandConnection) StopSpindle(index int) error {
+	ctx := context.Background()
+	if _, err := bcc.spindle(ctx, index); err != nil {
+		return err
+	}
+	return bcc.setSpindle(ctx, index, fmt.Sprintf("M5 P%d", index), 0)
+}
+
+// setSpindle runs the given code and waits until the active RPM of the spindle matches rpm
+func (bcc *BaseCommandConnection) setSpindle(ctx context.Context, index int, code string, rpm float64) error {
+	if err := bcc.performJobCode(ctx, code); err != nil {
+		return err
+	}
+	return bcc.awaitModel(func() (bool, error) {
+		s, err := bcc.spindle(ctx, index)
+		if err != nil {
+			return false, err
+		}
+		return math.Abs(math.Abs(s.Active)-rpm) <= spindleRpmTolerance, nil
+	})
+}
+
+// spindle queries the given spindle from the object model
+func (bcc *BaseCommandConnection) spindle(ctx context.Context, index int) (*spindles.Spindle, error) {
+	var ss []*spindles.Spindle
+	if err := bcc.GetObjectModelKeyCtx(ctx, "spindles", &ss); err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(ss) || ss[index] == nil {
+		return nil, fmt.Errorf("Spindle %d does not exist", index)
+	}
+	return ss[index], nil
+}
+
+// SetLaserPower sets the laser power of the following moves (M3) as a fraction between 0 and 1 and waits
+// until the object model reports it. This requires the machine to be in laser mode (M452) and assumes
+// the default PWM scale of M452 (R255).
+func (bcc *BaseCommandConnection) SetLaserPower(fraction float64) error {
+	if fraction < 0 || fraction > 1 || math.IsNaN(fraction) {
+		return fmt.Errorf("Invalid laser power %v", fraction)
+	}
+	ctx := context.Background()
+	var st state.State
+	if err := bcc.GetObjectModelKeyCtx(ctx, "state", &st); err != nil {
+		return err
+	}
+	if st.MachineMode != state.Laser {
+		return fmt.Errorf("Machine is in %s mode instead of laser mode", st.MachineMode)
+	}
+	code := fmt.Sprintf("M3 S%s", strconv.FormatFloat(fraction*laserPwmScale, 'f', -1, 64))
+	if err := bcc.performJobCode(ctx, code); err != nil {
+		return err
+	}
+	return bcc.awaitModel(func() (bool, error) {
+		if err := bcc.GetObjectModelKeyCtx(ctx, "state", &st); err != nil {
+			return false, err
+		}
+		return st.LaserPwm != nil && math.Abs(*st.LaserPwm-fraction) <= laserPwmTolerance, nil
+	})
+}
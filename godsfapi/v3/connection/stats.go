@@ -0,0 +1,58 @@
+package connection
+
+import "time"
+
+// ConnectionStats is a snapshot of the state of a connection, e.g. for health checks
+type ConnectionStats struct {
+	// Connected is true if the connection is established and has not failed or been closed
+	Connected bool
+	// ConnectionId is the ID assigned by the control server on connect
+	ConnectionId int64
+	// ServerVersion is the API version reported by the control server on connect
+	ServerVersion int64
+	// LastResponse is the time the last response was received or the zero time if there was none
+	LastResponse time.Time
+	// PendingRequests is the number of commands that are still waiting for their responses
+	PendingRequests int
+	// Reconnects is the number of times the connection was re-established by a ReconnectingConnection
+	Reconnects int
+}
+
+// SinceLastResponse returns the time that has passed since the last response was received
+// or zero if there was none
+func (cs ConnectionStats) SinceLastResponse() time.Duration {
+	if cs.LastResponse.IsZero() {
+		return 0
+	}
+	return time.Since(cs.LastResponse)
+}
+
+// connectionStats are the counters kept for ConnectionStats
+type connectionStats struct {
+	id            int64
+	serverVersion int64
+	lastResponse  time.Time
+	pending       int
+	reconnects    int
+}
+
+// Stats returns a snapshot of the state of this connection. It is safe to call concurrently.
+func (bc *BaseConnection) Stats() ConnectionStats {
+	bc.stateMu.Lock()
+	defer bc.stateMu.Unlock()
+	return ConnectionStats{
+		Connected:       bc.live != nil && !bc.closing && !bc.disconnected,
+		ConnectionId:    bc.stats.id,
+		ServerVersion:   bc.stats.serverVersion,
+		LastResponse:    bc.stats.lastResponse,
+		PendingRequests: bc.stats.pending,
+		Reconnects:      bc.stats.reconnects,
+	}
+}
+
+// updateStats runs fn while holding the lock guarding the counters
+func (bc *BaseConnection) updateStats(fn func(s *connectionStats)) {
+	bc.stateMu.Lock()
+	defer bc.stateMu.Unlock()
+	fn(&bc.stats)
+}
@@ -1,12 +1,18 @@
 package connection
 
 import (
+	"errors"
+	"reflect"
+
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine"
 )
 
 // SubscribeConnection is used to subscribe for object model updates
+// Commands cannot be sent on this connection type so there is no keepalive. Use ReadTimeout
+// instead to detect stalled connections; unfiltered subscriptions receive updates at least
+// every second since the uptime of the machine is part of the object model.
 type SubscribeConnection struct {
 	BaseConnection
 	Mode initmessages.SubscriptionMode
@@ -16,14 +22,31 @@ type SubscribeConnection struct {
 	Filters []string
 }
 
-// Connect will send a SubscribeInitMessage to the control server
+// Connect will send a SubscribeInitMessage to the control server.
+// In Patch mode filters (e.g. "heat/heaters[*]/current" or "job/**") can be used to
+// only receive updates for the given paths. The deprecated Filter is transmitted as well if set.
 func (sc *SubscribeConnection) Connect(mode initmessages.SubscriptionMode, filters []string, socketPath string) error {
 	sc.Mode = mode
 	sc.Filters = filters
-	sim := initmessages.NewSubscribeInitMessage(mode, filters)
+	sim := &initmessages.SubscribeInitMessage{
+		BaseInitMessage:  initmessages.NewBaseInitMessage(initmessages.ConnectionModeSubscribe),
+		SubscriptionMode: mode,
+		Filter:           sc.Filter,
+		Filters:          filters,
+	}
 	return sc.BaseConnection.Connect(sim, socketPath)
 }
 
+// Reconnect closes this connection and subscribes again using the same settings, e.g. after an
+// emergency stop. As with any new subscription the full object model has to be received first.
+func (sc *SubscribeConnection) Reconnect() error {
+	if sc.network == "" {
+		return errors.New("Connection was not established via Connect and cannot be re-established")
+	}
+	sc.Close()
+	return sc.connect(sc.initMessage, sc.network, sc.address)
+}
+
 // GetMachineModel retrieves the full object model of the machine.
 // In subscription mode this is the first command that has to be called once a connection has
 // been established
@@ -54,3 +77,68 @@ func (sc *SubscribeConnection) GetMachineModelPatch() (string, error) {
 	}
 	return j, nil
 }
+
+// ModelPatcher is implemented by machine.MachineModel and machine.SafeModel
+type ModelPatcher interface {
+	// ApplyPatch merges a JSON patch into the model
+	ApplyPatch(patch []byte) error
+}
+
+// ApplyMachineModelPatch receives a (partial) machine model update and merges it into the given model.
+// This also works with filtered subscriptions that only receive subtrees of the model.
+// Pass a machine.SafeModel to have the patch applied under its write lock.
+func (sc *SubscribeConnection) ApplyMachineModelPatch(mm ModelPatcher) error {
+	b, err := sc.ReceiveJson()
+	if err != nil {
+		return err
+	}
+	err = sc.Send(commands.NewAcknowledge())
+	if err != nil {
+		return err
+	}
+	return mm.ApplyPatch(b)
+}
+
+// WatchPluginData subscribes in Patch mode to the given plugin data key only and sends the value of
+// the key to the returned channel once subscribed and whenever it changes afterwards. The value is
+// nil if the plugin or key does not exist. Values are sent synchronously so updates are not
+// acknowledged until the previous value has been received.
+// Once the subscription fails the error is sent to the error channel and both channels are closed.
+func (sc *SubscribeConnection) WatchPluginData(plugin, key, socketPath string) (<-chan interface{}, <-chan error, error) {
+	if err := sc.Connect(initmessages.SubscriptionModePatch, []string{"plugins/" + plugin + "/data/" + key}, socketPath); err != nil {
+		return nil, nil, err
+	}
+	values := make(chan interface{}, 1)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(values)
+		defer close(errc)
+
+		mm, err := sc.GetMachineModel()
+		if err != nil {
+			errc <- err
+			return
+		}
+		last := pluginData(mm, plugin, key)
+		values <- last
+		for {
+			if err = sc.ApplyMachineModelPatch(mm); err != nil {
+				errc <- err
+				return
+			}
+			if v := pluginData(mm, plugin, key); !reflect.DeepEqual(v, last) {
+				last = v
+				values <- v
+			}
+		}
+	}()
+	return values, errc, nil
+}
+
+// pluginData returns the value of the given plugin data key or nil
+func pluginData(mm *machine.MachineModel, plugin, key string) interface{} {
+	if p, ok := mm.Plugins[plugin]; ok {
+		return p.Data[key]
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/state"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/tool"
+)
+
+// toolOffsetTolerance is the maximum difference between a requested and a reported tool offset
+const toolOffsetTolerance = 0.001
+
+// ErrNoToolSelected is returned by GetActiveTool if no tool is selected
+var ErrNoToolSelected = errors.New("No tool selected")
+
+// SelectTool selects the given tool (T-code) and waits until the object model reports it as current.
+// This includes running the tool change macros which may wait for the tool to heat up.
+// Pass -1 to deselect the current tool. An error is returned if the tool does not exist.
+func (bcc *BaseCommandConnection) SelectTool(number int) error {
+	ctx := context.Background()
+	if number != -1 {
+		if _, err := bcc.tool(ctx, number); err != nil {
+			return err
+		}
+	}
+	if err := bcc.performJobCode(ctx, "T"+strconv.Itoa(number)); err != nil {
+		return err
+	}
+	return bcc.awaitModel(func() (bool, error) {
+		var s state.State
+		if err := bcc.GetObjectModelKeyCtx(ctx, "state", &s); err != nil {
+			return false, err
+		}
+		return s.CurrentTool == int64(number), nil
+	})
+}
+
+// SetToolOffset sets the X, Y and Z offsets of the given tool (G10) and waits until the object
+// model reports them. An error is returned if the tool does not exist.
+func (bcc *BaseCommandConnection) SetToolOffset(number int, offsets [3]float64) error {
+	ctx := context.Background()
+	if _, err := bcc.tool(ctx, number); err != nil {
+		return err
+	}
+	code := fmt.Sprintf("G10 P%d X%s Y%s Z%s", number,
+		strconv.FormatFloat(offsets[0], 'f', -1, 64),
+		strconv.FormatFloat(offsets[1], 'f', -1, 64),
+		strconv.FormatFloat(offsets[2], 'f', -1, 64))
+	if err := bcc.performJobCode(ctx, code); err != nil {
+		return err
+	}
+	return bcc.awaitModel(func() (bool, error) {
+		t, err := bcc.tool(ctx, number)
+		if err != nil || len(t.Offsets) < len(offsets) {
+			return false, err
+		}
+		for i, o := range offsets {
+			if math.Abs(t.Offsets[i]-o) > toolOffsetTolerance {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// GetActiveTool returns the currently selected tool or ErrNoToolSelected
+func (bcc *BaseCommandConnection) GetActiveTool() (*tool.Tool, error) {
+	ctx := context.Background()
+	var s state.State
+	if err := bcc.GetObjectModelKeyCtx(ctx, "state", &s); err != nil {
+		return nil, err
+	}
+	if s.CurrentTool < 0 {
+		return nil, ErrNoToolSelected
+	}
+	return bcc.tool(ctx, int(s.CurrentTool))
+}
+
+// tool queries the given tool from the object model
+func (bcc *BaseCommandConnection) tool(ctx context.Context, number int) (*tool.Tool, error) {
+	var tools []*tool.Tool
+	if err := bcc.GetObjectModelKeyCtx(ctx, "tools", &tools); err != nil {
+		return nil, err
+	}
+	if number < 0 || number >= len(tools) || tools[number] == nil {
+		return nil, fmt.Errorf("Tool %d does not exist", number)
+	}
+	return tools[number], nil
+}
@@ -0,0 +1,81 @@
+package connection
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+)
+
+// ConnectionTransport carries the serialized messages exchanged with the control server.
+// Each call to Send and Receive handles exactly one JSON value.
+//
+// Connections established via Connect use a transport on top of a net.Conn. Custom
+// implementations (e.g. the one from package mock) can be used via ConnectTransport.
+// Implementations must be comparable and are usually pointer types.
+type ConnectionTransport interface {
+	// Send a single serialized message. data is reused once Send returns so it must not be retained.
+	Send(data []byte) error
+	// Receive the next serialized message. This blocks until a message is available.
+	// io.EOF is returned once the transport was closed.
+	Receive() ([]byte, error)
+	// Close the transport
+	Close() error
+}
+
+// deadlineTransport is implemented by transports that support read and write timeouts
+type deadlineTransport interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// valueTransport is implemented by transports that can decode the next message straight
+// into a value. This avoids holding an extra copy of large messages such as the object model.
+type valueTransport interface {
+	ReceiveValue(v interface{}) error
+}
+
+// netTransport is a ConnectionTransport on top of a net.Conn
+type netTransport struct {
+	conn    net.Conn
+	decoder *json.Decoder
+}
+
+// newNetTransport creates a new transport for the given net.Conn
+func newNetTransport(conn net.Conn) *netTransport {
+	return &netTransport{conn: conn, decoder: json.NewDecoder(conn)}
+}
+
+// Send writes a single serialized message to the socket
+func (t *netTransport) Send(data []byte) error {
+	_, err := t.conn.Write(data)
+	return err
+}
+
+// Receive reads the next JSON value from the socket
+func (t *netTransport) Receive() ([]byte, error) {
+	var raw json.RawMessage
+	if err := t.decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// ReceiveValue decodes the next JSON value from the socket into v
+func (t *netTransport) ReceiveValue(v interface{}) error {
+	return t.decoder.Decode(v)
+}
+
+// Close the socket
+func (t *netTransport) Close() error {
+	return t.conn.Close()
+}
+
+// SetReadDeadline sets the read deadline of the socket
+func (t *netTransport) SetReadDeadline(d time.Time) error {
+	return t.conn.SetReadDeadline(d)
+}
+
+// SetWriteDeadline sets the write deadline of the socket
+func (t *netTransport) SetWriteDeadline(d time.Time) error {
+	return t.conn.SetWriteDeadline(d)
+}
@@ -0,0 +1,118 @@
+package connection
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/initmessages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/connection/mock"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/heat"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/move"
+)
+
+// largeModelResponse returns a GetObjectModel response carrying a model of roughly a megabyte
+func largeModelResponse(b *testing.B) []byte {
+	mm := machine.NewMachineModel()
+	mm.Heat.Heaters = make([]heat.Heater, 256)
+	for i := range mm.Heat.Heaters {
+		mm.Heat.Heaters[i].Name = fmt.Sprintf("Heater %d", i)
+	}
+	mm.Move.Axes = make([]move.Axis, 64)
+	for i := range mm.Move.Axes {
+		mm.Move.Axes[i].Letter = string(rune('A' + i%26))
+	}
+	mm.Messages = make([]messages.Message, 4096)
+	for i := range mm.Messages {
+		mm.Messages[i] = messages.Message{Type: messages.Success, Content: fmt.Sprintf("Message %d of a long diagnostics reply", i)}
+	}
+	resp, err := json.Marshal(commands.BaseResponse{Success: true, Result: mm})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return resp
+}
+
+// repeatReader returns the same message over and over
+type repeatReader struct {
+	msg []byte
+	pos int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := copy(p, r.msg[r.pos:])
+	r.pos = (r.pos + n) % len(r.msg)
+	return n, nil
+}
+
+// bufferedTransport completes the handshake via the wrapped mock.Transport and reads all following
+// messages from a stream into a buffer first like netTransport.Receive
+type bufferedTransport struct {
+	*mock.Transport
+	decoder *json.Decoder
+}
+
+func newBufferedTransport(msg []byte) *bufferedTransport {
+	t := &bufferedTransport{Transport: mock.NewTransport(), decoder: json.NewDecoder(&repeatReader{msg: msg})}
+	t.EnqueueHandshake(1)
+	return t
+}
+
+func (t *bufferedTransport) Receive() ([]byte, error) {
+	if t.Pending() > 0 {
+		return t.Transport.Receive()
+	}
+	var raw json.RawMessage
+	err := t.decoder.Decode(&raw)
+	return raw, err
+}
+
+// streamingTransport decodes the messages following the handshake straight from the stream
+type streamingTransport struct {
+	*bufferedTransport
+}
+
+func (t *streamingTransport) ReceiveValue(v interface{}) error {
+	if t.Pending() > 0 {
+		b, err := t.Transport.Receive()
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	}
+	return t.decoder.Decode(v)
+}
+
+// BenchmarkGetObjectModel compares decoding a large object model from a buffered copy of the
+// message as done by transports without ReceiveValue with decoding it straight from the stream
+func BenchmarkGetObjectModel(b *testing.B) {
+	resp := largeModelResponse(b)
+	transports := []struct {
+		name      string
+		transport func() ConnectionTransport
+	}{
+		{"Buffered", func() ConnectionTransport { return newBufferedTransport(resp) }},
+		{"Streaming", func() ConnectionTransport { return &streamingTransport{newBufferedTransport(resp)} }},
+	}
+	for _, tt := range transports {
+		b.Run(tt.name, func(b *testing.B) {
+			var cc CommandConnection
+			if err := cc.ConnectTransport(initmessages.NewCommandInitMessage(), tt.transport()); err != nil {
+				b.Fatal(err)
+			}
+			defer cc.CloseNow()
+
+			b.SetBytes(int64(len(resp)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := cc.GetObjectModel(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
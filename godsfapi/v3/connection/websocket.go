@@ -0,0 +1,166 @@
+package connection
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/commands"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/httpendpoints"
+)
+
+const (
+	// WebSocketNormalClosure is the WebSocket status code to close a connection normally
+	WebSocketNormalClosure = 1000
+	// webSocketMessage is the status code passed along with regular messages
+	webSocketMessage = 200
+)
+
+var (
+	// ErrWebSocketClosed is returned when sending to a client that has been disconnected
+	ErrWebSocketClosed = errors.New("WebSocket is closed")
+	// ErrEmptyWebSocketMessage is returned when trying to send an empty message
+	ErrEmptyWebSocketMessage = errors.New("Empty WebSocket messages are not supported")
+)
+
+// WebSocketHandler is called in its own goroutine for each client that connects to a WebSocket endpoint.
+// The client is disconnected once the handler returns.
+type WebSocketHandler func(c *WebSocketClient)
+
+// WebSocketClient is a client connected to a WebSocket endpoint
+type WebSocketClient struct {
+	// Request holds the session, headers and query parameters the client connected with
+	Request *commands.ReceivedHttpRequest
+	conn    *HttpEndpointConnection
+	// mu serializes sending messages
+	mu     sync.Mutex
+	closed bool
+}
+
+// Receive waits for the next text message of the client.
+// An error is returned once the client has disconnected.
+func (c *WebSocketClient) Receive() ([]byte, error) {
+	rhr, err := c.conn.ReadRequest()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(rhr.Body), nil
+}
+
+// Send a text message to the client. Empty messages are not supported since
+// DuetWebServer closes the WebSocket in that case.
+func (c *WebSocketClient) Send(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrWebSocketClosed
+	}
+	if len(data) == 0 {
+		return ErrEmptyWebSocketMessage
+	}
+	return c.conn.SendResponse(webSocketMessage, string(data), commands.PlainText)
+}
+
+// Close the WebSocket using the given status code (>= 1000, see WebSocketNormalClosure)
+func (c *WebSocketClient) Close(statusCode uint16) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if statusCode < WebSocketNormalClosure {
+		statusCode = WebSocketNormalClosure
+	}
+	err := c.conn.SendResponse(statusCode, "", commands.StatusCode)
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// WebSocketEndpoint is a WebSocket endpoint that keeps track of its connected clients
+type WebSocketEndpoint struct {
+	*HttpEndpointUnixSocket
+	handler WebSocketHandler
+	// mu guards clients
+	mu      sync.Mutex
+	clients map[*WebSocketClient]struct{}
+}
+
+// AddWebSocketEndpoint adds a new third-party WebSocket endpoint in the format /machine/{ns}/{path}.
+// The handler is invoked for every client that connects; multiple clients are served concurrently.
+// Call Unregister once the endpoint is no longer needed. This happens automatically when the connection is closed.
+func (bcc *BaseCommandConnection) AddWebSocketEndpoint(ns, path string, handler WebSocketHandler) (*WebSocketEndpoint, error) {
+	e := &WebSocketEndpoint{handler: handler, clients: make(map[*WebSocketClient]struct{})}
+	heus, err := bcc.AddHttpEndpointHandler(httpendpoints.WebSocket, ns, path, false, e)
+	if err != nil {
+		return nil, err
+	}
+	e.HttpEndpointUnixSocket = heus
+	return e, nil
+}
+
+// Handle a newly connected client. DuetWebServer passes the initial request first.
+func (e *WebSocketEndpoint) Handle(h *HttpEndpointUnixSocket, hec *HttpEndpointConnection) {
+	rhr, err := hec.ReadRequest()
+	if err != nil {
+		hec.Close()
+		return
+	}
+	c := &WebSocketClient{Request: rhr, conn: hec}
+
+	e.mu.Lock()
+	e.clients[c] = struct{}{}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.clients, c)
+		e.mu.Unlock()
+		c.Close(WebSocketNormalClosure)
+	}()
+
+	e.handler(c)
+}
+
+// Clients returns the currently connected clients
+func (e *WebSocketEndpoint) Clients() []*WebSocketClient {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	clients := make([]*WebSocketClient, 0, len(e.clients))
+	for c := range e.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// Broadcast sends a text message to all connected clients. Clients that fail to
+// receive it are disconnected. The number of clients that got the message is returned.
+func (e *WebSocketEndpoint) Broadcast(data []byte) int {
+	n := 0
+	for _, c := range e.Clients() {
+		if err := c.Send(data); err != nil {
+			c.Close(WebSocketNormalClosure)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// Unregister removes the endpoint from the control server and disconnects all clients
+func (e *WebSocketEndpoint) Unregister() error {
+	err := e.HttpEndpointUnixSocket.Unregister()
+	for _, c := range e.Clients() {
+		c.Close(WebSocketNormalClosure)
+	}
+	return err
+}
+
+// Close the endpoint and disconnect all clients
+func (e *WebSocketEndpoint) Close() error {
+	err := e.HttpEndpointUnixSocket.Close()
+	for _, c := range e.Clients() {
+		c.Close(WebSocketNormalClosure)
+	}
+	return err
+}
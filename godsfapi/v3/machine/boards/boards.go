@@ -1,5 +1,7 @@
 package boards
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 // Board holds information about the electronics used
 type Board struct {
 	// BootloaderFileName is filename of firmware binary
@@ -67,3 +69,8 @@ const (
 	// Running if the board is up and running
 	Running = "running"
 )
+
+// Clone returns an independent deep copy of this board
+func (b *Board) Clone() *Board {
+	return deepcopy.Copy(b).(*Board)
+}
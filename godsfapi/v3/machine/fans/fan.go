@@ -1,5 +1,7 @@
 package fans
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 // Fan represents information about an attached fan
 type Fan struct {
 	// ActualValue is the current speed on a scale betweem 0 to 1 or -1 if unknown
@@ -34,3 +36,8 @@ type Thermostatic struct {
 	// on the fan (in degC)
 	LowTemperature *float64 `json:"lowTemperature"`
 }
+
+// Clone returns an independent deep copy of this fan
+func (f *Fan) Clone() *Fan {
+	return deepcopy.Copy(f).(*Fan)
+}
@@ -1,5 +1,7 @@
 package heat
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 const (
 	// AbsoluteZero temperature in degC
 	AbsoluteZero = -273.15
@@ -12,15 +14,15 @@ const (
 	// Off for a turned off heater
 	Off HeaterState = "off"
 	// Standby for a heater in standby mode
-	Standby = "standby"
+	Standby HeaterState = "standby"
 	// Active for an active heater
-	Active = "active"
+	Active HeaterState = "active"
 	// Fault for a faulted heater
-	Fault = "fault"
+	Fault HeaterState = "fault"
 	// Tuning for a tuning heater
-	Tuning = "tuning"
+	Tuning HeaterState = "tuning"
 	// Offline for a heater that cannot be reached
-	Offline = "offline"
+	Offline HeaterState = "offline"
 )
 
 // Default values for Heat
@@ -158,3 +160,13 @@ type HeaterMonitor struct {
 	// Limit threshold for this heater monitor
 	Limit *float64 `json:"limit"`
 }
+
+// Clone returns an independent deep copy of the heat subsystem
+func (h *Heat) Clone() *Heat {
+	return deepcopy.Copy(h).(*Heat)
+}
+
+// Clone returns an independent deep copy of this heater
+func (h *Heater) Clone() *Heater {
+	return deepcopy.Copy(h).(*Heater)
+}
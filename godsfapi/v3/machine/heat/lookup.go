@@ -0,0 +1,9 @@
+package heat
+
+// Heater returns the heater with the given index if it exists
+func (h *Heat) Heater(index int) (*Heater, bool) {
+	if index < 0 || index >= len(h.Heaters) {
+		return nil, false
+	}
+	return &h.Heaters[index], true
+}
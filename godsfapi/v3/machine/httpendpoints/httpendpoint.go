@@ -40,6 +40,6 @@ const (
 	DELETE = "DELETE"
 	// OPTIONS Request
 	OPTIONS = "OPTIONS"
-	// WebSocket request. This has not been implemented yet but is reserved for future usage
+	// WebSocket request
 	WebSocket = "WebSocket"
 )
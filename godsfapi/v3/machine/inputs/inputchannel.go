@@ -9,17 +9,17 @@ const (
 	// Default means no emulation (same as RepRapFirmware)
 	Default Compatibility = "Default"
 	// RepRapFirmware emulation (i.e. no emulation)
-	RepRapFirmware = "RepRapFirmware"
+	RepRapFirmware Compatibility = "RepRapFirmware"
 	// Marlin emulation
-	Marlin = "Marlin"
+	Marlin Compatibility = "Marlin"
 	// Teacup emulation
-	Teacup = "Teacup"
+	Teacup Compatibility = "Teacup"
 	// Sprinter emulation
-	Sprinter = "Sprinter"
+	Sprinter Compatibility = "Sprinter"
 	// Repetier emulation
-	Repetier = "Repetier"
+	Repetier Compatibility = "Repetier"
 	// NanoDLP emulation (special)
-	NanoDLP = "NanoDLP"
+	NanoDLP Compatibility = "NanoDLP"
 )
 
 // DistanceUnit used for positioning
@@ -29,7 +29,7 @@ const (
 	// MM represents millimeters
 	MM DistanceUnit = "MM"
 	// Inch represents inches
-	Inch = "Inch"
+	Inch DistanceUnit = "Inch"
 )
 
 // InputChannelState is the state of a channel
@@ -39,13 +39,13 @@ const (
 	// AwaitingAcknowledgement waits for message acknowledgement
 	AwaitingAcknowledgement InputChannelState = "awaitingAcknowledgement"
 	// Idle for an idle channel
-	Idle = "idle"
+	Idle InputChannelState = "idle"
 	// Executing if channel executes G/M/T-code
-	Executing = "executing"
+	Executing InputChannelState = "executing"
 	// Waiting for more data
-	Waiting = "waiting"
+	Waiting InputChannelState = "waiting"
 	// Reading a G/M/T-code
-	Reading = "reading"
+	Reading InputChannelState = "reading"
 )
 
 const (
@@ -1,19 +1,24 @@
 package job
 
+import (
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
 // Job holds information about the current file job (if any)
 type Job struct {
 	// Build holds information about the current build or nil if not available
 	Build *Build `json:"build"`
 	// Duration is the total duration of the current job in s
-	Duration *int `json:"duration"`
+	Duration *types.Seconds `json:"duration"`
 	// File holds ParsedFileInfo about the file being processed
 	File ParsedFileInfo `json:"file"`
 	// FilePosition is the current position in the file being processed in bytes
 	FilePosition *uint64 `json:"filePosition"`
 	// FirstLayerDuration is the duration of the first layer in s or nil if not available
-	FirstLayerDuration *int64 `json:"firstLayerDuration"`
+	FirstLayerDuration *types.Seconds `json:"firstLayerDuration"`
 	// LastDuration is the total duration of the last job in s or nil if not available
-	LastDuration *int64 `json:"lastDuration"`
+	LastDuration *types.Seconds `json:"lastDuration"`
 	// LastFileName is the name of the last processed file
 	LastFileName string `json:"lastFileName"`
 	// LastFileAborted indicated if the last file was aborted (unexpected cancellation)
@@ -25,19 +30,19 @@ type Job struct {
 	// Layer number of the current layer or nil if none has been started yet
 	Layer *int64 `json:"layer"`
 	// LayerTime is time elapsed since the beginning of the current layer in s or nil if unknown
-	LayerTime *int64 `json:"layerTime"`
+	LayerTime *types.Seconds `json:"layerTime"`
 	// Layers is a list of Layer information about past layers
 	Layers []Layer `json:"layers"`
 	// TimesLeft contains estimated remaining times
 	TimesLeft TimesLeft `json:"timesLeft"`
 	// WarmUpDuration is the time needed to heat up the heaters in s or nil if unknown
-	WarmUpDuration *int64 `json:"warmUpDuration"`
+	WarmUpDuration *types.Seconds `json:"warmUpDuration"`
 }
 
 // Layer holds information about a layer from a file being printed
 type Layer struct {
 	// Duration of the layer (in s)
-	Duration int `json:"duration"`
+	Duration types.Seconds `json:"duration"`
 	// Filament represents the actual amount of filament extruded during
 	// this layer in mm
 	Filament []float64 `json:"filament"`
@@ -45,15 +50,20 @@ type Layer struct {
 	// during this layer on a scale between 0 and 1
 	FractionPrinted float64 `json:"fractionPrinted"`
 	// Height of the layer in mm (0 if unknown)
-	Height float64 `json:"height"`
+	Height types.Millimeters `json:"height"`
 }
 
 // TimesLeft holds information about estimated remaining times
 type TimesLeft struct {
 	// File progress based estimation in s (nil if unknown)
-	File *int64 `json:"file"`
+	File *types.Seconds `json:"file"`
 	// Filament consumption based estimation in s (nil if unknown)
-	Filament *int64 `json:"filament"`
+	Filament *types.Seconds `json:"filament"`
 	// Layer progress based estimation in s (nil if unknown)
-	Layer *int64 `json:"layer"`
+	Layer *types.Seconds `json:"layer"`
+}
+
+// Clone returns an independent deep copy of this job
+func (j *Job) Clone() *Job {
+	return deepcopy.Copy(j).(*Job)
 }
@@ -1,20 +1,55 @@
 package job
 
 import (
+	"encoding/base64"
+	"errors"
 	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
+// ThumbnailFormat is the image format of a thumbnail
+type ThumbnailFormat string
+
+const (
+	// ThumbnailFormatJpeg for JPEG images
+	ThumbnailFormatJpeg ThumbnailFormat = "jpeg"
+	// ThumbnailFormatPng for PNG images
+	ThumbnailFormatPng ThumbnailFormat = "png"
+	// ThumbnailFormatQoi for QOI images
+	ThumbnailFormatQoi ThumbnailFormat = "qoi"
 )
 
 // Thumbnail holds image parsed out of GCode files
 type Thumbnail struct {
-
-	// EncodedImage is the base64 encoded image
+	// Data is the base64 encoded image or empty if the content was not requested
+	Data string `json:"data"`
+	// EncodedImage is the base64 encoded image as reported by older versions
 	EncodedImage string `json:"encodedImage"`
+	// Format of the image. This may be empty for older versions that only support PNG.
+	Format ThumbnailFormat `json:"format"`
 	// Height of thumbail
 	Height int64 `json:"height"`
+	// Offset of the encoded image in the G-code file (in bytes)
+	Offset int64 `json:"offset"`
+	// Size of the encoded image in the G-code file (in bytes)
+	Size int64 `json:"size"`
 	// Width of thumbail
 	Width int64 `json:"width"`
 }
 
+// Image decodes the base64 encoded image of this thumbnail
+func (t *Thumbnail) Image() ([]byte, error) {
+	encoded := t.Data
+	if encoded == "" {
+		encoded = t.EncodedImage
+	}
+	if encoded == "" {
+		return nil, errors.New("Thumbnail content was not transmitted")
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
 // ParsedFileInfo holds information about a parsed G-code file
 type ParsedFileInfo struct {
 	// Filament is the filament consumption per extruder drive (in mm)
@@ -22,21 +57,21 @@ type ParsedFileInfo struct {
 	// FileName of the G-code file
 	FileName string `json:"fileName"`
 	// FirstLayerHeight is the height of the first layer or 0 if not found (in mm)
-	FirstLayerHeight float64 `json:"firstLayerHeight"`
+	FirstLayerHeight types.Millimeters `json:"firstLayerHeight"`
 	// GeneratedBy is the name of the application that generated this file
 	GeneratedBy string `json:"generatedBy"`
 	// Height is the build height of the G-code job or 0 if not found (in mm)
-	Height float64 `json:"height"`
+	Height types.Millimeters `json:"height"`
 	// LastModified is the last date and time the file was modified or nil if none is set
 	LastModified *time.Time `json:"lastModified"` // TODO: This will probably need adjustment/custom type
 	// LayerHeight is the height of each layer above the first or 0 if not found (in mm)
-	LayerHeight float64 `json:"layerHeight"`
+	LayerHeight types.Millimeters `json:"layerHeight"`
 	// NumLayers is the number of total layers or 0 if unknown
 	NumLayers int64 `json:"numLayers"`
 	// PrintTime is the estimated job duration (in s)
-	PrintTime *uint64 `json:"printTime"`
+	PrintTime *types.Seconds `json:"printTime"`
 	// SimulatedTime is the estimated job duration from G-code simulation (in s)
-	SimulatedTime *uint64 `json:"simulatedTime"`
+	SimulatedTime *types.Seconds `json:"simulatedTime"`
 	// Size of the file in bytes
 	Size uint64 `json:"size"`
 	// Thumbnails is a collection of thumbnails parsed from GCode
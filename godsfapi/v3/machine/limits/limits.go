@@ -1,5 +1,7 @@
 package limits
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 // Limits configured for the machine
 type Limits struct {
 	// Axes is the maximum number of axes or nil if unknown
@@ -53,3 +55,8 @@ type Limits struct {
 	// ZProbes is the maximum number of Z-probes or nil if unknown
 	ZProbes *int64 `json:"zProbes"`
 }
+
+// Clone returns an independent deep copy of these limits
+func (l *Limits) Clone() *Limits {
+	return deepcopy.Copy(l).(*Limits)
+}
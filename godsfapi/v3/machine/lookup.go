@@ -0,0 +1,285 @@
+package machine
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrKeyNotFound is returned if a path segment does not exist
+	ErrKeyNotFound = errors.New("Key not found")
+	// ErrIndexOutOfRange is returned if an index exceeds the length of an array
+	ErrIndexOutOfRange = errors.New("Index out of range")
+	// ErrInvalidPath is returned for malformed paths
+	ErrInvalidPath = errors.New("Invalid path")
+	// ErrInvalidModelPath is returned if a path cannot be set via SetObjectModel
+	ErrInvalidModelPath = errors.New("Invalid object model path")
+)
+
+// LookupError is returned if a path could not be resolved
+type LookupError struct {
+	// Path that was looked up
+	Path string
+	// Segment of the path that could not be resolved
+	Segment string
+	// Err is one of ErrKeyNotFound, ErrIndexOutOfRange or ErrInvalidPath
+	Err error
+}
+
+func (e *LookupError) Unwrap() error { return e.Err }
+
+func (e *LookupError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("Failed to look up %s at %s: %v", e.Path, e.Segment, e.Err)
+}
+
+// Lookup resolves a dotted path using the JSON names of the object model such as
+// "heat.heaters[0].current" or "move.axes[2].machinePosition" and returns its value.
+// Pointers are dereferenced and nil is returned for unset values.
+func Lookup(model *MachineModel, path string) (interface{}, error) {
+	v, err := lookupValue(model, path)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	return v.Interface(), nil
+}
+
+// LookupInto resolves a dotted path like Lookup and stores the value in dest which
+// has to be a non-nil pointer. Numeric values are converted if necessary.
+func LookupInto(model *MachineModel, path string, dest interface{}) error {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return fmt.Errorf("Destination must be a non-nil pointer, got %T", dest)
+	}
+	v, err := lookupValue(model, path)
+	if err != nil {
+		return err
+	}
+	e := d.Elem()
+	switch {
+	case !v.IsValid():
+		e.Set(reflect.Zero(e.Type()))
+	case v.Type().AssignableTo(e.Type()):
+		e.Set(v)
+	case isNumeric(v.Kind()) && isNumeric(e.Kind()):
+		e.Set(v.Convert(e.Type()))
+	default:
+		return fmt.Errorf("Cannot store value of %s (%s) in %T", path, v.Type(), dest)
+	}
+	return nil
+}
+
+// lookupValue resolves the given path and returns the dereferenced value.
+// The returned value is invalid if the path points to a nil value.
+func lookupValue(model *MachineModel, path string) (reflect.Value, error) {
+	v := reflect.ValueOf(model)
+	if path == "" {
+		return deref(v), nil
+	}
+	for _, segment := range strings.Split(path, ".") {
+		name := segment
+		var indices []string
+		if i := strings.IndexByte(segment, '['); i >= 0 {
+			if !strings.HasSuffix(segment, "]") {
+				return reflect.Value{}, &LookupError{Path: path, Segment: segment, Err: ErrInvalidPath}
+			}
+			name = segment[:i]
+			indices = strings.Split(segment[i+1:len(segment)-1], "][")
+		}
+		if name == "" {
+			return reflect.Value{}, &LookupError{Path: path, Segment: segment, Err: ErrInvalidPath}
+		}
+
+		var ok bool
+		if v, ok = child(deref(v), name); !ok {
+			return reflect.Value{}, &LookupError{Path: path, Segment: segment, Err: ErrKeyNotFound}
+		}
+		for _, is := range indices {
+			i, err := strconv.Atoi(is)
+			if err != nil {
+				return reflect.Value{}, &LookupError{Path: path, Segment: segment, Err: ErrInvalidPath}
+			}
+			v = deref(v)
+			if !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+				return reflect.Value{}, &LookupError{Path: path, Segment: segment, Err: ErrKeyNotFound}
+			}
+			if i < 0 || i >= v.Len() {
+				return reflect.Value{}, &LookupError{Path: path, Segment: segment, Err: ErrIndexOutOfRange}
+			}
+			v = v.Index(i)
+		}
+	}
+	return deref(v), nil
+}
+
+// ValidateModelPath checks if the given path refers to an atomic property of the object model
+// schema that can be set via SetObjectModel. It uses the same format as Lookup.
+// Since only the schema is checked, array indices and map keys are not required to exist in the
+// current model. Dynamic subtrees such as plugin data or kinematics parameters accept any path.
+// The returned error wraps ErrInvalidModelPath.
+func ValidateModelPath(path string) error {
+	t := reflect.TypeOf(MachineModel{})
+	if path == "" {
+		return invalidModelPath(path, "path is empty")
+	}
+	for _, segment := range strings.Split(path, ".") {
+		name := segment
+		var indices []string
+		if i := strings.IndexByte(segment, '['); i >= 0 {
+			if !strings.HasSuffix(segment, "]") {
+				return invalidModelPath(path, "malformed segment "+segment)
+			}
+			name = segment[:i]
+			indices = strings.Split(segment[i+1:len(segment)-1], "][")
+		}
+		if name == "" {
+			return invalidModelPath(path, "malformed segment "+segment)
+		}
+
+		t = derefType(t)
+		switch t.Kind() {
+		case reflect.Interface:
+			return nil
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return invalidModelPath(path, segment+" has no string keys")
+			}
+			t = t.Elem()
+		case reflect.Struct:
+			f, ok := childField(t, name)
+			if !ok {
+				return invalidModelPath(path, "unknown property "+name)
+			}
+			t = f
+		default:
+			return invalidModelPath(path, "cannot resolve "+name+" in "+t.Kind().String())
+		}
+		for _, is := range indices {
+			if i, err := strconv.Atoi(is); err != nil || i < 0 {
+				return invalidModelPath(path, "invalid index in "+segment)
+			}
+			t = derefType(t)
+			if t.Kind() == reflect.Interface {
+				return nil
+			}
+			if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+				return invalidModelPath(path, name+" is not an array")
+			}
+			t = t.Elem()
+		}
+	}
+	// Types with their own JSON representation like time.Time are atomic as well
+	if reflect.PtrTo(derefType(t)).Implements(textMarshalerType) {
+		return nil
+	}
+	switch derefType(t).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return invalidModelPath(path, "not an atomic property")
+	}
+	return nil
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// invalidModelPath returns an error wrapping ErrInvalidModelPath
+func invalidModelPath(path, reason string) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidModelPath, path, reason)
+}
+
+// childField returns the type of the struct field with the given JSON name
+func childField(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			if ft := derefType(f.Type); ft.Kind() == reflect.Struct {
+				if c, ok := childField(ft, name); ok {
+					return c, true
+				}
+			}
+			continue
+		}
+		if tag == name || (tag == "" && strings.EqualFold(f.Name, name)) {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+// derefType follows pointer types
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// child returns the struct field with the given JSON name or the map entry with the given key
+func child(v reflect.Value, name string) (reflect.Value, bool) {
+	if !v.IsValid() {
+		return reflect.Value{}, false
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if f.Anonymous && tag == "" {
+				if c, ok := child(deref(v.Field(i)), name); ok {
+					return c, true
+				}
+				continue
+			}
+			if tag == name || (tag == "" && strings.EqualFold(f.Name, name)) {
+				return v.Field(i), true
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		c := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if c.IsValid() {
+			return c, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// deref follows pointers and interfaces and returns an invalid value for nil
+func deref(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isNumeric checks if the given kind is a numeric type
+func isNumeric(k reflect.Kind) bool {
+	return (k >= reflect.Int && k <= reflect.Uint64) || k == reflect.Float32 || k == reflect.Float64
+}
@@ -2,6 +2,7 @@ package messages
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -36,3 +37,25 @@ func (m Message) String() string {
 		return m.Content
 	}
 }
+
+// Split splits a message consisting of multiple lines into one message per non-empty line.
+// RepRapFirmware often packs several replies into one message so lines starting with
+// "Error: " or "Warning: " are converted to messages of that type without the prefix.
+// Other lines keep the type of this message.
+func (m Message) Split() []Message {
+	var result []Message
+	for _, line := range strings.Split(m.Content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		msg := Message{Time: m.Time, Type: m.Type, Content: line}
+		if c := strings.TrimPrefix(line, "Error: "); c != line {
+			msg.Type, msg.Content = Error, c
+		} else if c := strings.TrimPrefix(line, "Warning: "); c != line {
+			msg.Type, msg.Content = Warning, c
+		}
+		result = append(result, msg)
+	}
+	return result
+}
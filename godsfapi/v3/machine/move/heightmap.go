@@ -0,0 +1,149 @@
+package move
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// heightMapHeader is the prefix of the first line of every height map file
+const heightMapHeader = "RepRapFirmware height map file"
+
+// HeightMap holds the probed Z coordinates of a mesh grid as written by G29 S0 or G29 S3
+type HeightMap struct {
+	// XAxis is the letter of the first axis of the grid. Older files do not record it and always use X.
+	XAxis string
+	// YAxis is the letter of the second axis of the grid. Older files do not record it and always use Y.
+	YAxis string
+	// XMin is the X start coordinate of the grid
+	XMin float64
+	// XMax is the X end coordinate of the grid
+	XMax float64
+	// XSpacing is the spacing between probe points in X direction
+	XSpacing float64
+	// YMin is the Y start coordinate of the grid
+	YMin float64
+	// YMax is the Y end coordinate of the grid
+	YMax float64
+	// YSpacing is the spacing between probe points in Y direction
+	YSpacing float64
+	// Radius is the probing radius on delta kinematics or a value <= 0 if not applicable
+	Radius float64
+	// NumX is the number of probe points in X direction
+	NumX int
+	// NumY is the number of probe points in Y direction
+	NumY int
+	// ZCoordinates are the probed Z deviations indexed by [y][x] (in mm).
+	// Points that were not probed are set to NaN.
+	ZCoordinates [][]float64
+}
+
+// ParseHeightMap reads a height map in the CSV format used by RepRapFirmware
+func ParseHeightMap(r io.Reader) (*HeightMap, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(header[0], heightMapHeader) {
+		return nil, errors.New("Invalid height map file header")
+	}
+
+	names, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	values, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != len(values) {
+		return nil, errors.New("Invalid height map file parameters")
+	}
+
+	hm := &HeightMap{XAxis: "X", YAxis: "Y"}
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		value := strings.TrimSpace(values[i])
+		// Newer files name the axes of the grid
+		switch name {
+		case "axis0":
+			hm.XAxis = value
+			continue
+		case "axis1":
+			hm.YAxis = value
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for height map parameter %s", name)
+		}
+		switch name {
+		case "xmin", "min0":
+			hm.XMin = v
+		case "xmax", "max0":
+			hm.XMax = v
+		case "ymin", "min1":
+			hm.YMin = v
+		case "ymax", "max1":
+			hm.YMax = v
+		case "radius":
+			hm.Radius = v
+		case "spacing":
+			hm.XSpacing = v
+			hm.YSpacing = v
+		case "xspacing", "spacing0":
+			hm.XSpacing = v
+		case "yspacing", "spacing1":
+			hm.YSpacing = v
+		case "xnum", "num0":
+			hm.NumX = int(v)
+		case "ynum", "num1":
+			hm.NumY = int(v)
+		}
+	}
+	if hm.NumX <= 0 || hm.NumY <= 0 {
+		return nil, errors.New("Invalid number of height map points")
+	}
+
+	hm.ZCoordinates = make([][]float64, 0, hm.NumY)
+	for y := 0; y < hm.NumY; y++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("Height map file has %d instead of %d rows", y, hm.NumY)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) != hm.NumX {
+			return nil, fmt.Errorf("Height map row %d has %d instead of %d points", y, len(row), hm.NumX)
+		}
+		zs := make([]float64, hm.NumX)
+		for x, s := range row {
+			s = strings.TrimSpace(s)
+			if s == "0" {
+				// RepRapFirmware writes an integral zero for points that were not probed
+				zs[x] = math.NaN()
+				continue
+			}
+			zs[x], err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid height map value %s in row %d", s, y)
+			}
+		}
+		hm.ZCoordinates = append(hm.ZCoordinates, zs)
+	}
+	return hm, nil
+}
+
+// Point returns the X and Y coordinates of the grid point with the given indices
+func (hm *HeightMap) Point(x, y int) (float64, float64) {
+	return hm.XMin + float64(x)*hm.XSpacing, hm.YMin + float64(y)*hm.YSpacing
+}
@@ -0,0 +1,54 @@
+package move
+
+import "strings"
+
+// Axis returns the configured axis with the given letter (case-insensitive).
+// Use this instead of indexing Axes since the order of axes depends on the machine configuration.
+func (m *Move) Axis(letter string) (*Axis, bool) {
+	for i := range m.Axes {
+		if strings.EqualFold(m.Axes[i].Letter, letter) {
+			return &m.Axes[i], true
+		}
+	}
+	return nil, false
+}
+
+// Extruder returns the extruder with the given index if it is configured
+func (m *Move) Extruder(index int) (*Extruder, bool) {
+	if index < 0 || index >= len(m.Extruders) {
+		return nil, false
+	}
+	return &m.Extruders[index], true
+}
+
+// AxisLetters returns the letters of all configured axes in the order of Axes
+func (m *Move) AxisLetters() []string {
+	letters := make([]string, len(m.Axes))
+	for i, a := range m.Axes {
+		letters[i] = a.Letter
+	}
+	return letters
+}
+
+// MachinePosition returns the machine position of every axis keyed by its letter (in mm).
+// Axes whose position is unknown are omitted.
+func (m *Move) MachinePosition() map[string]float64 {
+	return m.positions(func(a *Axis) *float64 { return (*float64)(a.MachinePosition) })
+}
+
+// UserPosition returns the user position of every axis keyed by its letter (in mm).
+// Axes whose position is unknown are omitted.
+func (m *Move) UserPosition() map[string]float64 {
+	return m.positions(func(a *Axis) *float64 { return (*float64)(a.UserPosition) })
+}
+
+// positions collects the position selected by pos of every axis with a known position
+func (m *Move) positions(pos func(a *Axis) *float64) map[string]float64 {
+	result := make(map[string]float64, len(m.Axes))
+	for i := range m.Axes {
+		if p := pos(&m.Axes[i]); p != nil {
+			result[m.Axes[i].Letter] = *p
+		}
+	}
+	return result
+}
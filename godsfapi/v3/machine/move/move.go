@@ -1,5 +1,10 @@
 package move
 
+import (
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
+
 // Move holds information about the move subsystem
 type Move struct {
 	// Axes is a list of configured axes
@@ -52,7 +57,7 @@ type MoveCompensation struct {
 	// ProbeGrid holds the settings of the current probe grid
 	ProbeGrid ProbeGrid `json:"probeGrid"`
 	// Skew holds information about the configured orthogonal axis parameters
-	Skew Skew
+	Skew Skew `json:"skew"`
 	// Type is the type of compensation in use
 	Type MoveCompensationType `json:"type"`
 }
@@ -87,7 +92,7 @@ type Axis struct {
 	// Acceleration of this axis (in mm/s^2)
 	Acceleration float64 `json:"acceleration"`
 	// Babystep amount (in mm)
-	Babystep float64 `json:"babystep"`
+	Babystep types.Millimeters `json:"babystep"`
 	// Current of the motor (in mA)
 	Current int64 `json:"current"`
 	// Drivers list of assigned drivers
@@ -99,15 +104,15 @@ type Axis struct {
 	// Letter assigned to this axis (always upper-case)
 	Letter string `json:"letter"`
 	// MachinePosition is the current machine position (in mm or nil if unknown)
-	MachinePosition *float64 `json:"machinePosition"`
+	MachinePosition *types.Millimeters `json:"machinePosition"`
 	// Max travel of this axis (in mm)
-	Max float64 `json:"max"`
+	Max types.Millimeters `json:"max"`
 	// MaxProbed is ture if the maximum was probed
 	MaxProbed bool `json:"maxProbed"`
 	// Microstepping of this axis
 	Microstepping Microstepping `json:"microstepping"`
 	// Min travel of this axis (in mm)
-	Min float64 `json:"min"`
+	Min types.Millimeters `json:"min"`
 	// MinProbed is true if the minimum was probed
 	MinProbed bool `json:"minProbed"`
 	// Speed is the maximum speed (in mm/s)
@@ -115,7 +120,7 @@ type Axis struct {
 	// StepsPerMm for this axis
 	StepsPerMm float64 `json:"stepsPerMm"`
 	// UserPosition (in mm or nil if unknown)
-	UserPosition *float64 `json:"userPosition"`
+	UserPosition *types.Millimeters `json:"userPosition"`
 	// Visible is true if the axis is not explicitely hidden
 	Visible bool `json:"visible"`
 	// WorkplaceOffsets for this axis (in mm)
@@ -243,3 +248,18 @@ type Skew struct {
 	// TaxYZ is the tangent of the skew angle for YZ axes
 	TanYZ float64 `json:"tanYZ"`
 }
+
+// Clone returns an independent deep copy of the move subsystem
+func (m *Move) Clone() *Move {
+	return deepcopy.Copy(m).(*Move)
+}
+
+// Clone returns an independent deep copy of this axis
+func (a *Axis) Clone() *Axis {
+	return deepcopy.Copy(a).(*Axis)
+}
+
+// Clone returns an independent deep copy of this extruder
+func (e *Extruder) Clone() *Extruder {
+	return deepcopy.Copy(e).(*Extruder)
+}
@@ -1,5 +1,7 @@
 package network
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 const (
 	// DefaultName of the machine
 	DefaultName = "My Duet"
@@ -25,10 +27,14 @@ type NetworkProtocol string
 const (
 	// HTTP protocol
 	HTTP NetworkProtocol = "http"
+	// HTTPS protocol
+	HTTPS NetworkProtocol = "https"
 	// FTP protocol
-	FTP = "ftp"
+	FTP NetworkProtocol = "ftp"
+	// SFTP protocol
+	SFTP NetworkProtocol = "sftp"
 	// Telnet protocol
-	Telnet = "telnet"
+	Telnet NetworkProtocol = "telnet"
 )
 
 // Network holds information about the network subsytem
@@ -69,3 +75,8 @@ type NetworkInterface struct {
 	// Type of this network interface
 	Type InterfaceType `json:"type"`
 }
+
+// Clone returns an independent deep copy of the network subsystem
+func (n *Network) Clone() *Network {
+	return deepcopy.Copy(n).(*Network)
+}
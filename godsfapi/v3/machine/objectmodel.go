@@ -7,6 +7,7 @@ import (
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/heat"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/httpendpoints"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/inputs"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/job"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/limits"
 	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
@@ -49,8 +50,8 @@ type MachineModel struct {
 	Move move.Move `json:"move"`
 	// Network holds information about connected network adapters
 	Network network.Network `json:"network"`
-	// Plugins is the list of loaded SBC plugins
-	Plugins []plugins.Plugin
+	// Plugins holds the installed plugins by name
+	Plugins map[string]plugins.Plugin `json:"plugins"`
 	// Scanner holds information about the 3D scanner subsystem
 	Scanner scanner.Scanner `json:"scanner"`
 	// Sensors holds information about connected sensors including Z-probes and endstops
@@ -73,3 +74,8 @@ type MachineModel struct {
 func NewMachineModel() *MachineModel {
 	return &MachineModel{}
 }
+
+// Clone returns an independent deep copy of the full machine model
+func (mm *MachineModel) Clone() *MachineModel {
+	return deepcopy.Copy(mm).(*MachineModel)
+}
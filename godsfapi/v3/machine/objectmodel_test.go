@@ -0,0 +1,68 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/heat"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/messages"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/move"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/tool"
+)
+
+// populatedModel returns a model resembling a large machine with nested slices and pointers
+func populatedModel() *MachineModel {
+	mm := NewMachineModel()
+	mm.Heat.Heaters = make([]heat.Heater, 32)
+	for i := range mm.Heat.Heaters {
+		state := heat.Active
+		mm.Heat.Heaters[i].Name = fmt.Sprintf("Heater %d", i)
+		mm.Heat.Heaters[i].Current = float64(20 + i)
+		mm.Heat.Heaters[i].State = &state
+	}
+	mm.Move.Axes = make([]move.Axis, 9)
+	for i := range mm.Move.Axes {
+		mm.Move.Axes[i].Letter = string(rune('A' + i))
+	}
+	mm.Tools = make([]tool.Tool, 8)
+	for i := range mm.Tools {
+		mm.Tools[i].Number = int64(i)
+		mm.Tools[i].Name = fmt.Sprintf("Tool %d", i)
+		mm.Tools[i].Heaters = []int64{int64(i)}
+		mm.Tools[i].Extruders = []int64{int64(i)}
+		mm.Tools[i].Active = []float64{210}
+		mm.Tools[i].Standby = []float64{150}
+	}
+	mm.Messages = make([]messages.Message, 256)
+	for i := range mm.Messages {
+		mm.Messages[i] = messages.Message{Type: messages.Success, Content: fmt.Sprintf("Message %d", i)}
+	}
+	return mm
+}
+
+// BenchmarkClone compares Clone to copying the model by a JSON round trip
+func BenchmarkClone(b *testing.B) {
+	mm := populatedModel()
+
+	b.Run("Clone", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = mm.Clone()
+		}
+	})
+
+	b.Run("JSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.Marshal(mm)
+			if err != nil {
+				b.Fatal(err)
+			}
+			c := NewMachineModel()
+			if err = json.Unmarshal(data, c); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
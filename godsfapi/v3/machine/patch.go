@@ -0,0 +1,264 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ChangeType describes how a path was changed by a patch
+type ChangeType string
+
+const (
+	// PathAdded if the path did not exist before
+	PathAdded ChangeType = "added"
+	// PathModified if the value of the path changed
+	PathModified ChangeType = "modified"
+	// PathRemoved if the path does not exist anymore
+	PathRemoved ChangeType = "removed"
+)
+
+// PathChange is a single change caused by applying a patch
+type PathChange struct {
+	// Path is the dotted path using JSON names and array indices, e.g. heat.heaters[0].current
+	Path string
+	// Type of the change
+	Type ChangeType
+}
+
+// ApplyPatch merges a JSON patch as received from a subscription in Patch mode into this model.
+// Objects are merged recursively and null values reset the corresponding fields. Arrays are
+// merged item by item so unchanged items may be sent as empty objects and the array is
+// truncated or extended to the length of the patched array.
+// Patches that only contain a subtree of the model, e.g. when using subscription filters,
+// leave all other parts untouched.
+func (mm *MachineModel) ApplyPatch(patch []byte) error {
+	_, _, err := mm.applyPatch(patch, false)
+	return err
+}
+
+// ApplyPatchChanges merges a JSON patch the same way as ApplyPatch and returns the list of
+// changed paths sorted by path. Objects or arrays that were created wholesale are reported as
+// a single added path, items of shrinking arrays as removed paths.
+func (mm *MachineModel) ApplyPatchChanges(patch []byte) ([]PathChange, error) {
+	before, after, err := mm.applyPatch(patch, true)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]PathChange, 0)
+	diff("", before, after, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// Diff compares two models and returns the list of changed paths sorted by path
+// using the same notation as ApplyPatchChanges
+func Diff(old, new *MachineModel) ([]PathChange, error) {
+	o, err := toGeneric(old)
+	if err != nil {
+		return nil, err
+	}
+	n, err := toGeneric(new)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]PathChange, 0)
+	diff("", o, n, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// ApplyPatch merges a JSON patch into any part of the model, e.g. a *heat.Heat when the patch was
+// received from a subscription filtered to heat, using the same semantics as MachineModel.ApplyPatch.
+// dst has to be a non-nil pointer. It is left untouched if the patch cannot be applied.
+func ApplyPatch(dst interface{}, patch json.RawMessage) error {
+	_, _, err := applyPatch(dst, patch, false)
+	return err
+}
+
+// MergeRawPatch merges a JSON patch into the raw JSON document doc using the same semantics as
+// MachineModel.ApplyPatch and returns the merged document. Unlike the typed model the raw document
+// keeps fields that are not modelled by MachineModel yet. An empty doc is treated as an empty object.
+func MergeRawPatch(doc, patch json.RawMessage) (json.RawMessage, error) {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	var d interface{}
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &d); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(mergePatch(d, p))
+}
+
+// applyPatch merges patch into this model and, if changes is set, returns the generic
+// representations of the model before and after
+func (mm *MachineModel) applyPatch(patch []byte, changes bool) (interface{}, interface{}, error) {
+	return applyPatch(mm, patch, changes)
+}
+
+// applyPatch merges patch into the value dst points to and, if changes is set, returns the
+// generic representations of the value before and after. Otherwise both are nil.
+func applyPatch(dst interface{}, patch []byte, changes bool) (interface{}, interface{}, error) {
+	d := reflect.ValueOf(dst)
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return nil, nil, fmt.Errorf("Patch destination must be a non-nil pointer, got %T", dst)
+	}
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, nil, err
+	}
+	// Marshal once and decode a second copy only if the original is needed for the diff
+	// since mergePatch modifies current in place
+	b, err := json.Marshal(dst)
+	if err != nil {
+		return nil, nil, err
+	}
+	var current, before interface{}
+	if err = json.Unmarshal(b, &current); err != nil {
+		return nil, nil, err
+	}
+	if changes {
+		if err = json.Unmarshal(b, &before); err != nil {
+			return nil, nil, err
+		}
+	}
+	merged := mergePatch(current, p)
+
+	b, err = json.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := reflect.New(d.Elem().Type())
+	if err = json.Unmarshal(b, v.Interface()); err != nil {
+		return nil, nil, err
+	}
+	d.Elem().Set(v.Elem())
+	if !changes {
+		return nil, nil, nil
+	}
+
+	// Compare against the value as it is now to not report fields unknown to its type
+	after, err := toGeneric(dst)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// toGeneric converts v to its generic JSON representation
+func toGeneric(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var g interface{}
+	if err = json.Unmarshal(b, &g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// mergePatch merges patch into target and returns the result
+func mergePatch(target, patch interface{}) interface{} {
+	switch p := patch.(type) {
+	case map[string]interface{}:
+		t, ok := target.(map[string]interface{})
+		if !ok {
+			t = make(map[string]interface{}, len(p))
+		}
+		for k, v := range p {
+			if v == nil {
+				delete(t, k)
+				continue
+			}
+			t[k] = mergePatch(t[k], v)
+		}
+		return t
+	case []interface{}:
+		t, _ := target.([]interface{})
+		merged := make([]interface{}, len(p))
+		for i, v := range p {
+			var ti interface{}
+			if i < len(t) {
+				ti = t[i]
+			}
+			if v == nil {
+				merged[i] = nil
+				continue
+			}
+			merged[i] = mergePatch(ti, v)
+		}
+		return merged
+	default:
+		return patch
+	}
+}
+
+// diff collects the changes between old and new below the given path
+func diff(path string, old, new interface{}, changes *[]PathChange) {
+	switch n := new.(type) {
+	case map[string]interface{}:
+		o, ok := old.(map[string]interface{})
+		if !ok {
+			*changes = append(*changes, PathChange{Path: path, Type: changeType(old, new)})
+			return
+		}
+		for k, v := range n {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if ov, ok := o[k]; ok {
+				diff(p, ov, v, changes)
+			} else {
+				*changes = append(*changes, PathChange{Path: p, Type: PathAdded})
+			}
+		}
+		for k := range o {
+			if _, ok := n[k]; !ok {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				*changes = append(*changes, PathChange{Path: p, Type: PathRemoved})
+			}
+		}
+	case []interface{}:
+		o, ok := old.([]interface{})
+		if !ok {
+			*changes = append(*changes, PathChange{Path: path, Type: changeType(old, new)})
+			return
+		}
+		for i, v := range n {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			if i < len(o) {
+				diff(p, o[i], v, changes)
+			} else {
+				*changes = append(*changes, PathChange{Path: p, Type: PathAdded})
+			}
+		}
+		for i := len(n); i < len(o); i++ {
+			*changes = append(*changes, PathChange{Path: path + "[" + strconv.Itoa(i) + "]", Type: PathRemoved})
+		}
+	default:
+		if !reflect.DeepEqual(old, new) {
+			*changes = append(*changes, PathChange{Path: path, Type: changeType(old, new)})
+		}
+	}
+}
+
+// changeType determines the type of change of a single value
+func changeType(old, new interface{}) ChangeType {
+	if old == nil {
+		return PathAdded
+	}
+	if new == nil {
+		return PathRemoved
+	}
+	return PathModified
+}
@@ -0,0 +1,94 @@
+package plugins
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ManifestFile is the name of the plugin manifest inside a plugin ZIP bundle
+const ManifestFile = "plugin.json"
+
+var (
+	// semverPattern matches semantic versions such as 1.2.3 or 1.2.3-beta.1+build5
+	semverPattern = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+	// compatibilityPattern matches major/minor versions such as 3.2 optionally followed by further details
+	compatibilityPattern = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)([.\-+][0-9A-Za-z.\-+]*)?$`)
+	// namePattern matches valid plugin names
+	namePattern = regexp.MustCompile(`^[a-zA-Z0-9 .\-_]+$`)
+)
+
+// ValidateBundle opens the plugin ZIP bundle at the given path, locates its manifest
+// and validates it so malformed plugins can be rejected before sending InstallPlugin.
+// The parsed manifest is returned if it is valid.
+func ValidateBundle(path string) (*PluginManifest, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var mf *zip.File
+	for _, f := range zr.File {
+		if f.Name == ManifestFile {
+			mf = f
+			break
+		}
+	}
+	if mf == nil {
+		return nil, fmt.Errorf("Plugin bundle %s does not contain %s", path, ManifestFile)
+	}
+
+	r, err := mf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	pm := &PluginManifest{}
+	if err = json.NewDecoder(r).Decode(pm); err != nil {
+		return nil, fmt.Errorf("Invalid %s: %v", ManifestFile, err)
+	}
+	if err = pm.Validate(); err != nil {
+		return nil, err
+	}
+	return pm, nil
+}
+
+// Validate checks the manifest for missing required fields, malformed versions
+// and unknown permissions
+func (pm *PluginManifest) Validate() error {
+	if pm.Name == "" {
+		return errors.New("Missing plugin name")
+	}
+	if !namePattern.MatchString(pm.Name) {
+		return fmt.Errorf("Invalid plugin name %s", pm.Name)
+	}
+	if pm.Version == "" {
+		return errors.New("Missing plugin version")
+	}
+	if !semverPattern.MatchString(pm.Version) {
+		return fmt.Errorf("Plugin version %s is not a semantic version", pm.Version)
+	}
+	versions := []struct{ field, value string }{
+		{"dwcVersion", pm.DwcVersion},
+		{"sbcDsfVersion", pm.SbcDsfVersion},
+		{"rrfVersion", pm.RrfVersion},
+	}
+	for _, v := range versions {
+		if v.value != "" && !compatibilityPattern.MatchString(v.value) {
+			return fmt.Errorf("Invalid %s %s", v.field, v.value)
+		}
+	}
+	if pm.SbcExecutable != "" && pm.SbcDsfVersion == "" {
+		return errors.New("Missing sbcDsfVersion for plugin with SBC executable")
+	}
+	for _, p := range pm.SbcPermissions {
+		if !p.IsValid() {
+			return fmt.Errorf("Unknown permission %s", p)
+		}
+	}
+	return nil
+}
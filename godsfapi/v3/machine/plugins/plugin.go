@@ -1,15 +1,21 @@
 package plugins
 
-import "github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+import (
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/types"
+)
 
 // Plugin represents a loaded plugin
 type Plugin struct {
+	PluginManifest
 	// DwcFiles is a list of files for DWC
 	DwcFiles []string `json:"dwcFiles"`
 	// SbcFiles is a list of installed SBC files in the plugin directory
 	SbcFiles []string `json:"sbcFiles"`
 	// RrfFiles is a list of RRF files on the (virtual) SD excluding web files
 	RrfFiles []string `json:"rrfFiles"`
+	// Data holds the custom plugin data set via SetPluginData
+	Data map[string]interface{} `json:"data"`
 	// Pid is the process ID of the plugin or -1 if not started
 	// This may become 0 when the plugin has been stopped and the application
 	// is being shut down
@@ -61,3 +67,13 @@ type PluginManifest struct {
 	// RrfVersion is the major/minore supported RRF version (optional)
 	RrfVersion string `json:"rrfVersion"`
 }
+
+// IsStarted returns true if the SBC executable of this plugin is running
+func (p *Plugin) IsStarted() bool {
+	return p.Pid > 0
+}
+
+// Clone returns an independent deep copy of this plugin
+func (p *Plugin) Clone() *Plugin {
+	return deepcopy.Copy(p).(*Plugin)
+}
@@ -0,0 +1,89 @@
+package machine
+
+import "sync"
+
+// SafeModel guards a MachineModel for concurrent access, e.g. when a subscriber
+// goroutine applies patches while other goroutines read the model
+type SafeModel struct {
+	mu      sync.RWMutex
+	model   *MachineModel
+	version uint64
+}
+
+// NewSafeModel creates a new SafeModel for the given model or an empty one if mm is nil
+func NewSafeModel(mm *MachineModel) *SafeModel {
+	if mm == nil {
+		mm = NewMachineModel()
+	}
+	return &SafeModel{model: mm}
+}
+
+// With runs fn under the read lock. The model must neither be modified nor be
+// retained after fn returned. Use Clone to obtain an independent copy instead.
+func (sm *SafeModel) With(fn func(mm *MachineModel)) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	fn(sm.model)
+}
+
+// Update runs fn under the write lock
+func (sm *SafeModel) Update(fn func(mm *MachineModel)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	fn(sm.model)
+	sm.version++
+}
+
+// Set replaces the guarded model, e.g. by a full model received via subscription
+func (sm *SafeModel) Set(mm *MachineModel) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.model = mm
+	sm.version++
+}
+
+// Clone returns a deep copy of the guarded model
+func (sm *SafeModel) Clone() *MachineModel {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.model.Clone()
+}
+
+// ApplyPatch merges a JSON patch into the guarded model under the write lock
+func (sm *SafeModel) ApplyPatch(patch []byte) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if err := sm.model.ApplyPatch(patch); err != nil {
+		return err
+	}
+	sm.version++
+	return nil
+}
+
+// ApplyPatchChanges merges a JSON patch into the guarded model under the write lock
+// and returns the list of changed paths
+func (sm *SafeModel) ApplyPatchChanges(patch []byte) ([]PathChange, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	changes, err := sm.model.ApplyPatchChanges(patch)
+	if err != nil {
+		return nil, err
+	}
+	sm.version++
+	return changes, nil
+}
+
+// Version returns the number of times the guarded model has been written to. Readers can
+// remember it to detect later whether their view of the model has become stale.
+func (sm *SafeModel) Version() uint64 {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.version
+}
+
+// Snapshot returns a deep copy of the guarded model together with its version
+func (sm *SafeModel) Snapshot() (*MachineModel, uint64) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.model.Clone(), sm.version
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -24,9 +25,44 @@ func toFilamentMonitor(src interface{}) (FilamentMonitor, error) {
 	return fm, nil
 }
 
-// GetType returns the FilamentMonitorType of this instance
+// GetType returns the FilamentMonitorType of this instance or Unkown if it is not set
 func (f FilamentMonitor) GetType() FilamentMonitorType {
-	return FilamentMonitorType(f["type"].(string))
+	t, ok := f["type"].(string)
+	if !ok {
+		return Unkown
+	}
+	return FilamentMonitorType(t)
+}
+
+// Decode returns a typed representation of this filament monitor depending on its type,
+// i.e. *SimpleFilamentMonitor, *LaserFilamentMonitor, *PulsedFilamentMonitor,
+// *RotatingMagnetFilamentMonitor or *BaseFilamentMonitor for unknown types
+func (f FilamentMonitor) Decode() (TypedFilamentMonitor, error) {
+	var tfm TypedFilamentMonitor
+	switch f.GetType() {
+	case Simple:
+		tfm = &SimpleFilamentMonitor{}
+	case Laser:
+		tfm = &LaserFilamentMonitor{}
+	case Pulsed:
+		tfm = &PulsedFilamentMonitor{}
+	case RotatingMagnet:
+		tfm = &RotatingMagnetFilamentMonitor{}
+	default:
+		tfm = &BaseFilamentMonitor{}
+	}
+	if err := mapstructure.Decode(f, tfm); err != nil {
+		return nil, err
+	}
+	return tfm, nil
+}
+
+// TypedFilamentMonitor is implemented by all typed filament monitors
+type TypedFilamentMonitor interface {
+	// Base returns the properties shared by all filament monitors
+	Base() *BaseFilamentMonitor
+	// AsFilamentMonitor returns this instance as FilamentMonitor
+	AsFilamentMonitor() (FilamentMonitor, error)
 }
 
 // FilamentMonitors is a slice of FilamentMonitor
@@ -35,15 +71,39 @@ type FilamentMonitors []FilamentMonitor
 // ErrInvalidIndex is returned in case an invalid index is accessed
 var ErrInvalidIndex = errors.New("Invalid index")
 
-// GetAsBaseFilamentMonitor returns the instance at the given index as BaseFilamentMonitor
-func (fm FilamentMonitors) GetAsBaseFilamentMonitor(i int) (*BaseFilamentMonitor, error) {
-	if i < 0 || i > len(fm) {
+// Get returns the typed filament monitor at the given index (see FilamentMonitor.Decode).
+// nil is returned if no filament monitor is configured at this index.
+func (fm FilamentMonitors) Get(i int) (TypedFilamentMonitor, error) {
+	if i < 0 || i >= len(fm) {
 		return nil, ErrInvalidIndex
 	}
-	f := fm[i]
+	if fm[i] == nil {
+		return nil, nil
+	}
+	return fm[i].Decode()
+}
+
+// ByExtruder returns the typed filament monitors indexed by the extruder they are assigned to.
+// Extruders without a filament monitor are omitted.
+func (fm FilamentMonitors) ByExtruder() (map[int]TypedFilamentMonitor, error) {
+	result := make(map[int]TypedFilamentMonitor)
+	for extruder, f := range fm {
+		if f == nil {
+			continue
+		}
+		tfm, err := f.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode filament monitor of extruder %d: %v", extruder, err)
+		}
+		result[extruder] = tfm
+	}
+	return result, nil
+}
+
+// GetAsBaseFilamentMonitor returns the instance at the given index as BaseFilamentMonitor
+func (fm FilamentMonitors) GetAsBaseFilamentMonitor(i int) (*BaseFilamentMonitor, error) {
 	bfm := &BaseFilamentMonitor{}
-	err := mapstructure.Decode(f, bfm)
-	if err != nil {
+	if err := fm.decode(i, "", bfm); err != nil {
 		return nil, err
 	}
 	return bfm, nil
@@ -51,17 +111,8 @@ func (fm FilamentMonitors) GetAsBaseFilamentMonitor(i int) (*BaseFilamentMonitor
 
 // GetAsSimpleFilamentMonitor returns the instance at the given index as SimpleFilamentMonitor
 func (fm FilamentMonitors) GetAsSimpleFilamentMonitor(i int) (*SimpleFilamentMonitor, error) {
-	if i < 0 || i > len(fm) {
-		return nil, ErrInvalidIndex
-	}
-	f := fm[i]
-	name := f.GetType()
-	if name != Simple {
-		return nil, fmt.Errorf("Not SimpleFilamentMonitor: %s", name)
-	}
 	sfm := &SimpleFilamentMonitor{}
-	err := mapstructure.Decode(f, sfm)
-	if err != nil {
+	if err := fm.decode(i, Simple, sfm); err != nil {
 		return nil, err
 	}
 	return sfm, nil
@@ -69,17 +120,8 @@ func (fm FilamentMonitors) GetAsSimpleFilamentMonitor(i int) (*SimpleFilamentMon
 
 // GetAsLaserFilamentMonitor returns the instance at the given index as LaserFilamentMonitor
 func (fm FilamentMonitors) GetAsLaserFilamentMonitor(i int) (*LaserFilamentMonitor, error) {
-	if i < 0 || i > len(fm) {
-		return nil, ErrInvalidIndex
-	}
-	f := fm[i]
-	name := f.GetType()
-	if name != Laser {
-		return nil, fmt.Errorf("Not LaserFilamentMonitor: %s", name)
-	}
 	lfm := &LaserFilamentMonitor{}
-	err := mapstructure.Decode(f, lfm)
-	if err != nil {
+	if err := fm.decode(i, Laser, lfm); err != nil {
 		return nil, err
 	}
 	return lfm, nil
@@ -87,17 +129,8 @@ func (fm FilamentMonitors) GetAsLaserFilamentMonitor(i int) (*LaserFilamentMonit
 
 // GetAsPulsedFilamentMonitor returns the instance at the given index as PulsedFilamentMonitor
 func (fm FilamentMonitors) GetAsPulsedFilamentMonitor(i int) (*PulsedFilamentMonitor, error) {
-	if i < 0 || i > len(fm) {
-		return nil, ErrInvalidIndex
-	}
-	f := fm[i]
-	name := f.GetType()
-	if name != Pulsed {
-		return nil, fmt.Errorf("Not PulsedFilamentMonitor: %s", name)
-	}
 	pfm := &PulsedFilamentMonitor{}
-	err := mapstructure.Decode(f, pfm)
-	if err != nil {
+	if err := fm.decode(i, Pulsed, pfm); err != nil {
 		return nil, err
 	}
 	return pfm, nil
@@ -105,22 +138,26 @@ func (fm FilamentMonitors) GetAsPulsedFilamentMonitor(i int) (*PulsedFilamentMon
 
 // GetAsRotatingMagnetFilamentMonitor returns the instance at the given index as RotatingMagnetFilamentMonitor
 func (fm FilamentMonitors) GetAsRotatingMagnetFilamentMonitor(i int) (*RotatingMagnetFilamentMonitor, error) {
-	if i < 0 || i > len(fm) {
-		return nil, ErrInvalidIndex
-	}
-	f := fm[i]
-	name := f.GetType()
-	if name != RotatingMagnet {
-		return nil, fmt.Errorf("Not RotatingMagnetFilamentMonitor: %s", name)
-	}
 	rmfm := &RotatingMagnetFilamentMonitor{}
-	err := mapstructure.Decode(f, rmfm)
-	if err != nil {
+	if err := fm.decode(i, RotatingMagnet, rmfm); err != nil {
 		return nil, err
 	}
 	return rmfm, nil
 }
 
+// decode decodes the instance at the given index into dest after checking
+// its type unless t is empty
+func (fm FilamentMonitors) decode(i int, t FilamentMonitorType, dest interface{}) error {
+	if i < 0 || i >= len(fm) || fm[i] == nil {
+		return ErrInvalidIndex
+	}
+	f := fm[i]
+	if name := f.GetType(); t != "" && name != t {
+		return fmt.Errorf("Not %sFilamentMonitor: %s", strings.Title(string(t)), name)
+	}
+	return mapstructure.Decode(f, dest)
+}
+
 // FilamentMonitorStatus are the possible filament sensor statuses
 type FilamentMonitorStatus string
 
@@ -151,6 +188,11 @@ type BaseFilamentMonitor struct {
 	Type FilamentMonitorType `json:"type"`
 }
 
+// Base returns this instance
+func (bfm *BaseFilamentMonitor) Base() *BaseFilamentMonitor {
+	return bfm
+}
+
 // AsFilamentMonitor returns this instance as FilamentMonitor
 func (bfm *BaseFilamentMonitor) AsFilamentMonitor() (FilamentMonitor, error) {
 	return toFilamentMonitor(bfm)
@@ -0,0 +1,65 @@
+package sensors
+
+import "strconv"
+
+// probeTypeNames are the names of the probe types as used in the documentation of M558
+var probeTypeNames = []string{
+	"none", "analog", "dumbModulated", "alternateAnalog", "endstopSwitch", "digital",
+	"e1Switch", "zSwitch", "unfilteredDigital", "blTouch", "zMotorStall",
+}
+
+// String returns the name of this probe type
+func (pt ProbeType) String() string {
+	if pt < ProbeType(len(probeTypeNames)) {
+		return probeTypeNames[pt]
+	}
+	return "ProbeType(" + strconv.FormatUint(uint64(pt), 10) + ")"
+}
+
+// IsConfigured checks if this slot holds a configured probe.
+// Unconfigured probes are reported as null by the control server and decoded as None.
+func (p *Probe) IsConfigured() bool {
+	return p.Type != None
+}
+
+// IsConfigured checks if this slot holds a configured analog sensor
+func (as *AnalogSensor) IsConfigured() bool {
+	return as.Type != ""
+}
+
+// IsConfigured checks if this slot holds a configured endstop
+func (e *Endstop) IsConfigured() bool {
+	return e.Type != ""
+}
+
+// Probe returns the configured probe with the given index
+func (s *Sensors) Probe(index int) (*Probe, bool) {
+	if index < 0 || index >= len(s.Probes) || !s.Probes[index].IsConfigured() {
+		return nil, false
+	}
+	return &s.Probes[index], true
+}
+
+// AnalogSensor returns the configured analog sensor with the given index
+func (s *Sensors) AnalogSensor(index int) (*AnalogSensor, bool) {
+	if index < 0 || index >= len(s.Analog) || !s.Analog[index].IsConfigured() {
+		return nil, false
+	}
+	return &s.Analog[index], true
+}
+
+// Endstop returns the configured endstop of the axis with the given index
+func (s *Sensors) Endstop(index int) (*Endstop, bool) {
+	if index < 0 || index >= len(s.Endstops) || !s.Endstops[index].IsConfigured() {
+		return nil, false
+	}
+	return &s.Endstops[index], true
+}
+
+// GpInputPort returns the configured general-purpose input port with the given index
+func (s *Sensors) GpInputPort(index int) (*GpInputPort, bool) {
+	if index < 0 || index >= len(s.GpIn) || s.GpIn[index] == nil {
+		return nil, false
+	}
+	return s.GpIn[index], true
+}
@@ -1,5 +1,7 @@
 package sensors
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 // Sensors holds information about sensors
 type Sensors struct {
 	// Analog is a list of analog sensors
@@ -30,20 +32,20 @@ type AnalogSensorType string
 // Valid AnalogSensorType values
 const (
 	Thermistor    AnalogSensorType = "thermistor"
-	PT1000                         = "pt1000"
-	MAX31865                       = "rtdmax31865"
-	MAX31855                       = "thermocouplemax31855"
-	MAX31856                       = "thermocouplemax31856"
-	LinearAnalaog                  = "linearanalaog"
-	DHT11                          = "dth11"
-	DHT21                          = "dht21"
-	DHT22                          = "dht22"
-	DHTHumidity                    = "dhthumidity"
-	CurrentLoop                    = "currentlooppyro"
-	McuTemp                        = "mcutemp"
-	Drivers                        = "drivers"
-	DriversDuex                    = "driversduex"
-	Unknown                        = "unknown"
+	PT1000        AnalogSensorType = "pt1000"
+	MAX31865      AnalogSensorType = "rtdmax31865"
+	MAX31855      AnalogSensorType = "thermocouplemax31855"
+	MAX31856      AnalogSensorType = "thermocouplemax31856"
+	LinearAnalaog AnalogSensorType = "linearanalaog"
+	DHT11         AnalogSensorType = "dth11"
+	DHT21         AnalogSensorType = "dht21"
+	DHT22         AnalogSensorType = "dht22"
+	DHTHumidity   AnalogSensorType = "dhthumidity"
+	CurrentLoop   AnalogSensorType = "currentlooppyro"
+	McuTemp       AnalogSensorType = "mcutemp"
+	Drivers       AnalogSensorType = "drivers"
+	DriversDuex   AnalogSensorType = "driversduex"
+	Unknown       AnalogSensorType = "unknown"
 )
 
 // Endstop holds information about an endstop
@@ -63,13 +65,13 @@ const (
 	// InputPin for a generic input pin
 	InputPin EndstopType = "inputPin"
 	// ZProbeAsEndstop if the Z-probe acts as endstop
-	ZProbeAsEndstop = "zProbeAsEndstop"
+	ZProbeAsEndstop EndstopType = "zProbeAsEndstop"
 	// MotorStallAny stops all the drives when triggered
-	MotorStallAny = "motorStallAny"
+	MotorStallAny EndstopType = "motorStallAny"
 	// MotorStallIndividual stops individual drives when triggered
-	MotorStallIndividual = "motorStallIndividual"
+	MotorStallIndividual EndstopType = "motorStallIndividual"
 	// EndstopTypeUnknown is the unkown type
-	EndstopTypeUnknown = "unknown"
+	EndstopTypeUnknown EndstopType = "unknown"
 )
 
 // GpInputPort holds details about a general-purpose input port
@@ -155,3 +157,8 @@ const (
 	// ZMotorStall provided by the stepper driver
 	ZMotorStall
 )
+
+// Clone returns an independent deep copy of the sensors subsystem
+func (s *Sensors) Clone() *Sensors {
+	return deepcopy.Copy(s).(*Sensors)
+}
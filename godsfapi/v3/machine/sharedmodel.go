@@ -0,0 +1,22 @@
+package machine
+
+// SharedModel is a single authoritative model shared by several connections, typically a
+// SubscribeConnection keeping it up-to-date and a CommandConnection reading it while issuing codes.
+// Every write increments its Version so readers can detect that the model changed since they last
+// looked at it. All methods of SafeModel are safe for concurrent use.
+type SharedModel struct {
+	SafeModel
+}
+
+// NewSharedModel creates a new SharedModel for the given model or an empty one if mm is nil
+func NewSharedModel(mm *MachineModel) *SharedModel {
+	if mm == nil {
+		mm = NewMachineModel()
+	}
+	return &SharedModel{SafeModel: SafeModel{model: mm}}
+}
+
+// Stale checks if the model has been written to since the given version was obtained
+func (sm *SharedModel) Stale(version uint64) bool {
+	return sm.Version() != version
+}
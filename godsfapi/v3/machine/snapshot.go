@@ -0,0 +1,25 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SaveSnapshot writes the full model as indented JSON to w using the same property names as DSF,
+// e.g. to attach it to a bug report. The snapshot can be loaded again using LoadSnapshot.
+func SaveSnapshot(model *MachineModel, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(model)
+}
+
+// LoadSnapshot reads a model previously written by SaveSnapshot or received from DSF.
+// Only a single JSON object is read from r.
+func LoadSnapshot(r io.Reader) (*MachineModel, error) {
+	mm := NewMachineModel()
+	if err := json.NewDecoder(r).Decode(mm); err != nil {
+		return nil, fmt.Errorf("Failed to load object model snapshot: %w", err)
+	}
+	return mm, nil
+}
@@ -1,5 +1,7 @@
 package spindles
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 const (
 	// DefaultMaxRpm is the maximum RPM of a spindle
 	DefaultMaxRpm = 10000.0
@@ -22,3 +24,8 @@ type Spindle struct {
 	// Tool number mapped to this spindle or -1 if not assigned
 	Tool int64 `json:"tool"`
 }
+
+// Clone returns an independent deep copy of this spindle
+func (s *Spindle) Clone() *Spindle {
+	return deepcopy.Copy(s).(*Spindle)
+}
@@ -1,6 +1,10 @@
 package state
 
-import "time"
+import (
+	"time"
+
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+)
 
 const (
 	// NoTool is the tool index if no tool is selected
@@ -66,9 +70,9 @@ const (
 	// FFF is Fused Filament Fabrication (default)
 	FFF MachineMode = "FFF"
 	// CNC is computer numerical control
-	CNC = "CNC"
+	CNC MachineMode = "CNC"
 	// Laser for laser operation mode (e.g. laser cutters)
-	Laser = "Laser"
+	Laser MachineMode = "Laser"
 )
 
 // MachineStatus represents possibile states of the firmware
@@ -78,25 +82,25 @@ const (
 	// Updating while firmware is being updated
 	Updating MachineStatus = "updating"
 	// Off if the machine is turned off (i.e. the input voltage is too low for operation)
-	Off = "off"
+	Off MachineStatus = "off"
 	// Halted if the machine has encountered an emergency stop and is ready to reset
-	Halted = "halted"
+	Halted MachineStatus = "halted"
 	// Pausing if the machine is baout to pause a file job
-	Pausing = "pausing"
+	Pausing MachineStatus = "pausing"
 	// Paused if the machine has paused a file job
-	Paused = "paused"
+	Paused MachineStatus = "paused"
 	// Resuming if the machine is about to resume a paused file job
-	Resuming = "resuming"
+	Resuming MachineStatus = "resuming"
 	// Processing if the machine is processing a file job
-	Processing = "processing"
+	Processing MachineStatus = "processing"
 	// Simulating while the machine is simulation a file job to determine its processing time
-	Simulating = "simulating"
+	Simulating MachineStatus = "simulating"
 	// Busy if the machine is busy doing something (e.g. moving)
-	Busy = "busy"
+	Busy MachineStatus = "busy"
 	// ChangingTool if the machine is chaging tools
-	ChangingTool = "changingTool"
+	ChangingTool MachineStatus = "changingTool"
 	// Idle if the machine is on but idle
-	Idle = "idle"
+	Idle MachineStatus = "idle"
 )
 
 // RestorePoint holds information about a restore point
@@ -116,3 +120,8 @@ type RestorePoint struct {
 	// ToolNumber of the tool that was active
 	ToolNumber int64 `json:"toolNumber"`
 }
+
+// Clone returns an independent deep copy of the machine state
+func (s *State) Clone() *State {
+	return deepcopy.Copy(s).(*State)
+}
@@ -0,0 +1,47 @@
+package tool
+
+import (
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/heat"
+	"github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/move"
+)
+
+// ResolveHeaters resolves the heaters of this tool from the heat part of the same model.
+// The result is in the same order as Heaters, Active and Standby. Dangling indices that do
+// not refer to an existing heater, e.g. because the model was only partially received, are nil.
+func (t *Tool) ResolveHeaters(h *heat.Heat) []*heat.Heater {
+	heaters := make([]*heat.Heater, len(t.Heaters))
+	for i, index := range t.Heaters {
+		heaters[i], _ = h.Heater(int(index))
+	}
+	return heaters
+}
+
+// ResolveExtruders resolves the extruder drives of this tool from the move part of the same model.
+// The result is in the same order as Extruders and Mix. Dangling indices are nil.
+func (t *Tool) ResolveExtruders(m *move.Move) []*move.Extruder {
+	extruders := make([]*move.Extruder, len(t.Extruders))
+	for i, index := range t.Extruders {
+		extruders[i], _ = m.Extruder(int(index))
+	}
+	return extruders
+}
+
+// ActiveTemperature returns the active temperature of the first heater of this tool (in degC).
+// Use Active for tools with multiple heaters.
+func (t *Tool) ActiveTemperature() (float64, bool) {
+	return firstTemperature(t.Active)
+}
+
+// StandbyTemperature returns the standby temperature of the first heater of this tool (in degC).
+// Use Standby for tools with multiple heaters.
+func (t *Tool) StandbyTemperature() (float64, bool) {
+	return firstTemperature(t.Standby)
+}
+
+// firstTemperature returns the first temperature of the given list if there is one
+func firstTemperature(temperatures []float64) (float64, bool) {
+	if len(temperatures) == 0 {
+		return 0, false
+	}
+	return temperatures[0], true
+}
@@ -1,5 +1,7 @@
 package tool
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 // Default values for Tool
 const (
 	DefaultFilamentExtruder = -1
@@ -68,3 +70,8 @@ const (
 	// Standby for a tool in standby
 	Standby = "standby"
 )
+
+// Clone returns an independent deep copy of this tool
+func (t *Tool) Clone() *Tool {
+	return deepcopy.Copy(t).(*Tool)
+}
@@ -25,6 +25,11 @@ const (
 	ReadWrite = "readWrite"
 )
 
+// IsValid checks if this is one of the known access levels
+func (a AccessLevel) IsValid() bool {
+	return a == ReadOnly || a == ReadWrite
+}
+
 // SessionType is the type of user session
 type SessionType string
 
@@ -36,3 +41,13 @@ const (
 	// Telnet remote client
 	Telnet = "telnet"
 )
+
+// IsValid checks if this is one of the known session types
+func (t SessionType) IsValid() bool {
+	switch t {
+	case Local, HTTP, Telnet:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,5 +1,7 @@
 package volume
 
+import "github.com/Duet3D/DSF-APIs/godsfapi/v3/machine/internal/deepcopy"
+
 // Volume holds information about a storage device
 type Volume struct {
 	// Capacity is the total capacity of the storage device in bytes (0 for unknown)
@@ -17,3 +19,8 @@ type Volume struct {
 	// Speed of the storage device in bytes/s (0 for unknown)
 	Speed uint64 `json:"speed"`
 }
+
+// Clone returns an independent deep copy of this volume
+func (v *Volume) Clone() *Volume {
+	return deepcopy.Copy(v).(*Volume)
+}
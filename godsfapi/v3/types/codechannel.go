@@ -1,5 +1,14 @@
 package types
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCodeChannel is returned for channels unknown to the control server
+var ErrInvalidCodeChannel = errors.New("Invalid code channel")
+
 // CodeChannel represents supported input code channels
 type CodeChannel string
 
@@ -7,31 +16,31 @@ const (
 	// HTTP is the code channel for HTTP requests
 	HTTP CodeChannel = "HTTP"
 	// Telnet is the code channel for Telnet requests
-	Telnet = "Telnet"
+	Telnet CodeChannel = "Telnet"
 	// File is the code channel for file jobs
-	File = "File"
+	File CodeChannel = "File"
 	// USB is the code channel for codes from USB
-	USB = "USB"
+	USB CodeChannel = "USB"
 	// Aux is the code channel of serial devices except USB (e.g. PanelDue)
-	Aux = "Aux"
+	Aux CodeChannel = "Aux"
 	// Trigger is the code channel running triggers or config.g
-	Trigger = "Trigger"
+	Trigger CodeChannel = "Trigger"
 	// Queue is the code channel for the code queue that executes a couple of
 	// codes in-sync with moves
-	Queue = "Queue"
+	Queue CodeChannel = "Queue"
 	// LCD is the code channel for auxiliary LCD devices (e.g. PanelOne)
-	LCD = "LCD"
+	LCD CodeChannel = "LCD"
 	// SBC is the default code channel for requests of SBC
-	SBC = "SBC"
-	// Daemon is the code channel for running triggers or config.g
-	Daemon = "Daemon"
+	SBC CodeChannel = "SBC"
+	// Daemon is the code channel for daemon.g and other codes running in the background
+	Daemon CodeChannel = "Daemon"
 	// Aux2 is the code channel for the second UART port
-	Aux2 = "Aux2"
+	Aux2 CodeChannel = "Aux2"
 	// AutoPause is the code channel that executes macros on power fail,
 	// heater faults and filament out
-	AutoPause = "AutoPause"
+	AutoPause CodeChannel = "AutoPause"
 	// Unknown code channel
-	Unknown = "Unknown"
+	Unknown CodeChannel = "Unknown"
 
 	// DefaultChannel is the default channel to use
 	DefaultChannel CodeChannel = SBC
@@ -41,3 +50,36 @@ const (
 func AllChannels() []CodeChannel {
 	return []CodeChannel{HTTP, Telnet, File, USB, Aux, Trigger, Queue, LCD, SBC, Daemon, Aux2, AutoPause, Unknown}
 }
+
+// String returns the name of the channel
+func (c CodeChannel) String() string {
+	return string(c)
+}
+
+// IsValid checks if this is one of the channels returned by AllChannels
+func (c CodeChannel) IsValid() bool {
+	for _, ch := range AllChannels() {
+		if c == ch {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error wrapping ErrInvalidCodeChannel if this is not a valid channel
+func (c CodeChannel) Validate() error {
+	if !c.IsValid() {
+		return fmt.Errorf("%w: %q", ErrInvalidCodeChannel, string(c))
+	}
+	return nil
+}
+
+// ParseCodeChannel returns the channel with the given name ignoring case
+func ParseCodeChannel(s string) (CodeChannel, error) {
+	for _, ch := range AllChannels() {
+		if strings.EqualFold(s, string(ch)) {
+			return ch, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidCodeChannel, s)
+}
@@ -1,61 +1,80 @@
 package types
 
+// SbcPermissions are permissions a third-party plugin may request
 type SbcPermissions string
 
 const (
 	// None for no permissions set (default value)
 	None SbcPermissions = "none"
 	// CommandExecution to execute generic commands
-	CommandExecution = "commandExecution"
+	CommandExecution SbcPermissions = "commandExecution"
 	// CodeInterceptionRead to intercept codes in a non-blocking way
-	CodeInterceptionRead = "codeInterceptionRead"
+	CodeInterceptionRead SbcPermissions = "codeInterceptionRead"
 	// CodeInterceptionReadWrite to intercept codes in a blocking way
 	// with options to resolve or cancel them
-	CodeInterceptionReadWrite = "codeInterceptionReadWrite"
+	CodeInterceptionReadWrite SbcPermissions = "codeInterceptionReadWrite"
 	// ManagePlugins to install, load, unload and uninstall plugins
-	ManagePlugins = "managePlugins"
+	ManagePlugins SbcPermissions = "managePlugins"
 	// ManageUserSession to manage user sessions
-	ManageUserSession = "manageUserSessions"
+	ManageUserSession SbcPermissions = "manageUserSessions"
 	// ObjectModelRead to read from the object model
-	ObjectModelRead = "objectModelRead"
+	ObjectModelRead SbcPermissions = "objectModelRead"
 	// ObjectModelReadWrite to read from and write to the object model
-	ObjectModelReadWrite = "objectModelReadWrite"
+	ObjectModelReadWrite SbcPermissions = "objectModelReadWrite"
 	// RegisterHttpEndpoints to create new HTTP endpoints
-	RegisterHttpEndpoints = "registerHttpEndpoints"
+	RegisterHttpEndpoints SbcPermissions = "registerHttpEndpoints"
 	// ReadFilaments to read files in 0:/filaments
-	ReadFilaments = "readFilaments"
+	ReadFilaments SbcPermissions = "readFilaments"
 	// WriteFilaments to write files in 0:/filaments
-	WriteFilaments = "writeFilaments"
+	WriteFilaments SbcPermissions = "writeFilaments"
 	// ReadFirmware to read files in 0:/firmware
-	ReadFirmware = "readFirmware"
+	ReadFirmware SbcPermissions = "readFirmware"
 	// WriteFirmware to write files in 0:/firmware
-	WriteFirmware = "writeFirmware"
+	WriteFirmware SbcPermissions = "writeFirmware"
 	// ReadGCodes to read files in 0:/gcodes
-	ReadGCodes = "readGCodes"
+	ReadGCodes SbcPermissions = "readGCodes"
 	// WriteGCodes to write files in 0:/gcodes
-	WriteGCodes = "writeGCodes"
+	WriteGCodes SbcPermissions = "writeGCodes"
 	// ReadMacros to read files in 0:/macros
-	ReadMacros = "readMacros"
+	ReadMacros SbcPermissions = "readMacros"
 	// WriteMacros to write files in 0:/macros
-	WriteMacros = "writeMacros"
+	WriteMacros SbcPermissions = "writeMacros"
 	// ReadMenu to read files in 0:/menu
-	ReadMenu = "readMenu"
+	ReadMenu SbcPermissions = "readMenu"
 	// WriteMenu to write files in 0:/menu
-	WriteMenu = "WriteMenu"
+	WriteMenu SbcPermissions = "writeMenu"
 	// ReadSystem to read files in 0:/sys
-	ReadSystem = "readSystem"
+	ReadSystem SbcPermissions = "readSystem"
 	// WriteSystem to write files in 0:/sys
-	WriteSystem = "writeSystem"
+	WriteSystem SbcPermissions = "writeSystem"
 	// ReadWeb to read files in 0:/www
-	ReadWeb = "readWeb"
+	ReadWeb SbcPermissions = "readWeb"
 	// WriteWeb to write files in 0:/www
-	WriteWeb = "writeWeb"
+	WriteWeb SbcPermissions = "writeWeb"
 	// FileSystemAccess to access files outside the virtual SD directory (as DSF user)
-	FileSystemAccess = "fileSystemAccess"
+	FileSystemAccess SbcPermissions = "fileSystemAccess"
 	// LaunchProcess to launch new processes
-	LaunchProcess = "launchProcess"
+	LaunchProcess SbcPermissions = "launchProcess"
 	// NetworkAccess to communicat over network (stand-alone)
-	NetworkAccess = "networkAccess"
+	NetworkAccess SbcPermissions = "networkAccess"
 	// SuperUser to launch processes as root user (for full device control - potentially dangerous)
-	SuperUser = "superUser"
+	SuperUser SbcPermissions = "superUser"
 )
+
+// AllSbcPermissions returns a slice containing all known permissions
+func AllSbcPermissions() []SbcPermissions {
+	return []SbcPermissions{None, CommandExecution, CodeInterceptionRead, CodeInterceptionReadWrite, ManagePlugins,
+		ManageUserSession, ObjectModelRead, ObjectModelReadWrite, RegisterHttpEndpoints, ReadFilaments, WriteFilaments,
+		ReadFirmware, WriteFirmware, ReadGCodes, WriteGCodes, ReadMacros, WriteMacros, ReadMenu, WriteMenu,
+		ReadSystem, WriteSystem, ReadWeb, WriteWeb, FileSystemAccess, LaunchProcess, NetworkAccess, SuperUser}
+}
+
+// IsValid checks if this is a known permission
+func (p SbcPermissions) IsValid() bool {
+	for _, k := range AllSbcPermissions() {
+		if p == k {
+			return true
+		}
+	}
+	return false
+}
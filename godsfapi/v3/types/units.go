@@ -0,0 +1,61 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Seconds is a time span in seconds as reported by the object model
+type Seconds float64
+
+// Duration converts the time span to a time.Duration
+func (s Seconds) Duration() time.Duration {
+	return time.Duration(float64(s) * float64(time.Second))
+}
+
+// String returns the time span formatted like time.Duration, e.g. 1h2m3s
+func (s Seconds) String() string {
+	return s.Duration().String()
+}
+
+// UnmarshalJSON accepts plain numbers as well as numbers encoded as strings
+func (s *Seconds) UnmarshalJSON(data []byte) error {
+	return unmarshalNumber(data, (*float64)(s), "Seconds")
+}
+
+// Millimeters is a distance in mm as reported by the object model
+type Millimeters float64
+
+// String returns the distance including its unit, e.g. 12.5mm
+func (m Millimeters) String() string {
+	return strconv.FormatFloat(float64(m), 'f', -1, 64) + "mm"
+}
+
+// UnmarshalJSON accepts plain numbers as well as numbers encoded as strings
+func (m *Millimeters) UnmarshalJSON(data []byte) error {
+	return unmarshalNumber(data, (*float64)(m), "Millimeters")
+}
+
+// unmarshalNumber decodes a JSON number or numeric string into f. null leaves f unchanged.
+func unmarshalNumber(data []byte, f *float64, target string) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid value %q for %s", s, target)
+		}
+		*f = v
+		return nil
+	}
+	return json.Unmarshal(data, f)
+}